@@ -0,0 +1,209 @@
+package lager
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what an AsyncSink does with a log when its queue is
+// full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Log wait until there is room in the queue.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards the log being written.
+	OverflowDropNewest
+	// OverflowDropOldest discards the oldest queued log to make room.
+	OverflowDropOldest
+	// OverflowDropBelowLevel discards logs below the sink's drop level and
+	// blocks for everything else.
+	OverflowDropBelowLevel
+)
+
+// AsyncSink hands logs to a background goroutine which writes them to the
+// wrapped sink, so that callers of Log are not held up by slow writers.
+type AsyncSink struct {
+	sink      Sink
+	policy    OverflowPolicy
+	dropLevel LogLevel
+
+	lock     sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	drained  *sync.Cond
+
+	queue  []LogFormat
+	head   int
+	size   int
+	closed bool
+	done   chan struct{}
+	// waiting counts the writers blocked on a full queue, which the
+	// background goroutine keeps draining for even once Close is called
+	waiting int
+
+	closeOnce sync.Once
+	closeErr  error
+
+	// queued counts the logs ever queued and handled the ones written or
+	// dropped since, so that Flush can wait for the logs queued before it
+	// without waiting for the queue to empty.
+	queued  uint64
+	handled uint64
+
+	dropped uint64
+}
+
+// NewAsyncSink starts a goroutine that writes to sink and returns an
+// AsyncSink that queues up to queueSize logs for it. The dropLevel is only
+// used by OverflowDropBelowLevel.
+//
+// Close must be called to drain the queue and stop the goroutine.
+func NewAsyncSink(sink Sink, queueSize int, policy OverflowPolicy, dropLevel LogLevel) *AsyncSink {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	s := &AsyncSink{
+		sink:      sink,
+		policy:    policy,
+		dropLevel: dropLevel,
+		queue:     make([]LogFormat, queueSize),
+		done:      make(chan struct{}),
+	}
+	s.notEmpty = sync.NewCond(&s.lock)
+	s.notFull = sync.NewCond(&s.lock)
+	s.drained = sync.NewCond(&s.lock)
+
+	go s.run()
+
+	return s
+}
+
+// Log queues the log for the background goroutine. Logs written after Close
+// are dropped.
+func (s *AsyncSink) Log(log LogFormat) {
+	s.lock.Lock()
+
+	if s.closed {
+		s.lock.Unlock()
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+
+	for s.size == len(s.queue) {
+		switch s.policy {
+		case OverflowDropNewest:
+			s.lock.Unlock()
+			atomic.AddUint64(&s.dropped, 1)
+			return
+		case OverflowDropOldest:
+			s.pop()
+			s.handled++
+			s.drained.Broadcast()
+			atomic.AddUint64(&s.dropped, 1)
+		case OverflowDropBelowLevel:
+			if log.LogLevel < s.dropLevel {
+				s.lock.Unlock()
+				atomic.AddUint64(&s.dropped, 1)
+				return
+			}
+			s.waitNotFull()
+		default:
+			s.waitNotFull()
+		}
+	}
+
+	s.queue[(s.head+s.size)%len(s.queue)] = log
+	s.size++
+	s.queued++
+	s.notEmpty.Signal()
+	s.lock.Unlock()
+}
+
+func (s *AsyncSink) Enabled(level LogLevel) bool {
+	return SinkEnabled(s.sink, level)
+}
+
+// Dropped returns the number of logs discarded because the queue was full or
+// the sink was closed.
+func (s *AsyncSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Flush blocks until every log queued before the call has been written to the
+// wrapped sink, or dropped, and then flushes the wrapped sink. Logs queued
+// while Flush waits do not hold it up.
+func (s *AsyncSink) Flush() error {
+	s.lock.Lock()
+	queued := s.queued
+	for s.handled < queued {
+		s.drained.Wait()
+	}
+	s.lock.Unlock()
+
+	return FlushSink(s.sink)
+}
+
+// Close drains the queue, stops the background goroutine and closes the
+// wrapped sink. Writers already blocked on a full queue are let through
+// before it returns. It is safe to call Close more than once; the wrapped
+// sink is only closed once.
+func (s *AsyncSink) Close() error {
+	s.closeOnce.Do(func() {
+		s.lock.Lock()
+		s.closed = true
+		s.notEmpty.Broadcast()
+		s.lock.Unlock()
+
+		<-s.done
+		s.closeErr = CloseSink(s.sink)
+	})
+	return s.closeErr
+}
+
+// waitNotFull waits for room in the queue. The caller must hold the lock.
+func (s *AsyncSink) waitNotFull() {
+	s.waiting++
+	s.notFull.Wait()
+	s.waiting--
+	if s.waiting == 0 {
+		s.notEmpty.Signal()
+	}
+}
+
+func (s *AsyncSink) run() {
+	defer close(s.done)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for {
+		for s.size == 0 && (!s.closed || s.waiting > 0) {
+			s.notEmpty.Wait()
+		}
+
+		if s.size == 0 {
+			return
+		}
+
+		log := s.pop()
+		s.lock.Unlock()
+
+		s.sink.Log(log)
+
+		s.lock.Lock()
+		s.handled++
+		s.drained.Broadcast()
+	}
+}
+
+// pop removes the oldest log from the queue. The caller must hold the lock.
+func (s *AsyncSink) pop() LogFormat {
+	log := s.queue[s.head]
+	s.queue[s.head] = LogFormat{}
+	s.head = (s.head + 1) % len(s.queue)
+	s.size--
+	s.notFull.Signal()
+	return log
+}
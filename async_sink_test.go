@@ -0,0 +1,271 @@
+package lager_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// gatedSink blocks every call to Log until the gate is opened
+type gatedSink struct {
+	sink    lager.Sink
+	started chan struct{}
+	gate    chan struct{}
+	once    sync.Once
+}
+
+func newGatedSink(sink lager.Sink) *gatedSink {
+	return &gatedSink{
+		sink:    sink,
+		started: make(chan struct{}, 100),
+		gate:    make(chan struct{}),
+	}
+}
+
+func (s *gatedSink) Log(log lager.LogFormat) {
+	s.started <- struct{}{}
+	<-s.gate
+	s.sink.Log(log)
+}
+
+func (s *gatedSink) open() {
+	s.once.Do(func() { close(s.gate) })
+}
+
+// refillingSink queues another log on target for each log it writes while
+// refill is set, so that the queue of target never empties
+type refillingSink struct {
+	sink   lager.Sink
+	target *lager.AsyncSink
+	refill atomic.Bool
+}
+
+func (s *refillingSink) Log(log lager.LogFormat) {
+	if s.refill.Load() {
+		s.target.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "later"})
+	}
+	s.sink.Log(log)
+}
+
+var _ = Describe("AsyncSink", func() {
+	var (
+		testSink  *lagertest.TestSink
+		gated     *gatedSink
+		sink      *lager.AsyncSink
+		policy    lager.OverflowPolicy
+		dropLevel lager.LogLevel
+	)
+
+	BeforeEach(func() {
+		testSink = lagertest.NewTestSink()
+		gated = newGatedSink(testSink)
+		policy = lager.OverflowBlock
+		dropLevel = lager.DEBUG
+	})
+
+	JustBeforeEach(func() {
+		sink = lager.NewAsyncSink(gated, 2, policy, dropLevel)
+	})
+
+	AfterEach(func() {
+		gated.open()
+		Expect(sink.Close()).To(Succeed())
+	})
+
+	// fill blocks the background goroutine on the first log and then fills
+	// the queue behind it
+	fill := func() {
+		sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "in-flight"})
+		Eventually(gated.started).Should(Receive())
+		sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "queued-1"})
+		sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "queued-2"})
+	}
+
+	It("writes logs to the wrapped sink in order", func() {
+		gated.open()
+		sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "one"})
+		sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "two"})
+		sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "three"})
+
+		Expect(sink.Flush()).To(Succeed())
+		Expect(testSink.LogMessages()).To(Equal([]string{"one", "two", "three"}))
+	})
+
+	It("does not block the caller while the wrapped sink is busy", func() {
+		sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "one"})
+		Eventually(gated.started).Should(Receive())
+
+		Expect(testSink.LogMessages()).To(BeEmpty())
+	})
+
+	Context("when the queue is full", func() {
+		Context("with OverflowBlock", func() {
+			It("blocks until there is room", func() {
+				fill()
+
+				written := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "blocked"})
+					close(written)
+				}()
+				Consistently(written).ShouldNot(BeClosed())
+
+				gated.open()
+				Eventually(written).Should(BeClosed())
+				Expect(sink.Flush()).To(Succeed())
+				Expect(testSink.LogMessages()).To(Equal([]string{"in-flight", "queued-1", "queued-2", "blocked"}))
+				Expect(sink.Dropped()).To(BeZero())
+			})
+		})
+
+		Context("with OverflowDropNewest", func() {
+			BeforeEach(func() {
+				policy = lager.OverflowDropNewest
+			})
+
+			It("discards the new log", func() {
+				fill()
+				sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "dropped"})
+
+				gated.open()
+				Expect(sink.Flush()).To(Succeed())
+				Expect(testSink.LogMessages()).To(Equal([]string{"in-flight", "queued-1", "queued-2"}))
+				Expect(sink.Dropped()).To(BeEquivalentTo(1))
+			})
+		})
+
+		Context("with OverflowDropOldest", func() {
+			BeforeEach(func() {
+				policy = lager.OverflowDropOldest
+			})
+
+			It("discards the oldest queued log", func() {
+				fill()
+				sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "newest"})
+
+				gated.open()
+				Expect(sink.Flush()).To(Succeed())
+				Expect(testSink.LogMessages()).To(Equal([]string{"in-flight", "queued-2", "newest"}))
+				Expect(sink.Dropped()).To(BeEquivalentTo(1))
+			})
+		})
+
+		Context("with OverflowDropBelowLevel", func() {
+			BeforeEach(func() {
+				policy = lager.OverflowDropBelowLevel
+				dropLevel = lager.ERROR
+			})
+
+			It("discards logs below the drop level", func() {
+				fill()
+				sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "dropped"})
+				Expect(sink.Dropped()).To(BeEquivalentTo(1))
+
+				gated.open()
+				Expect(sink.Flush()).To(Succeed())
+				Expect(testSink.LogMessages()).NotTo(ContainElement("dropped"))
+			})
+
+			It("blocks for logs at or above the drop level", func() {
+				fill()
+
+				written := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					sink.Log(lager.LogFormat{LogLevel: lager.ERROR, Message: "kept"})
+					close(written)
+				}()
+				Consistently(written).ShouldNot(BeClosed())
+
+				gated.open()
+				Eventually(written).Should(BeClosed())
+				Expect(sink.Flush()).To(Succeed())
+				Expect(testSink.LogMessages()).To(ContainElement("kept"))
+				Expect(sink.Dropped()).To(BeZero())
+			})
+		})
+	})
+
+	Describe("Flush", func() {
+		It("waits for the logs queued before it, but not for the logs queued after", func() {
+			refilling := &refillingSink{sink: testSink}
+			refilling.refill.Store(true)
+			async := lager.NewAsyncSink(refilling, 2, lager.OverflowBlock, lager.DEBUG)
+			refilling.target = async
+
+			async.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "one"})
+			async.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "two"})
+
+			flushed := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(async.Flush()).To(Succeed())
+				close(flushed)
+			}()
+
+			Eventually(flushed).Should(BeClosed())
+			Expect(testSink.LogMessages()[:2]).To(Equal([]string{"one", "two"}))
+
+			refilling.refill.Store(false)
+			Expect(async.Close()).To(Succeed())
+		})
+	})
+
+	Describe("Close", func() {
+		It("drains the queue before returning", func() {
+			fill()
+			gated.open()
+
+			Expect(sink.Close()).To(Succeed())
+			Expect(testSink.LogMessages()).To(Equal([]string{"in-flight", "queued-1", "queued-2"}))
+		})
+
+		It("drops logs made after closing", func() {
+			gated.open()
+			Expect(sink.Close()).To(Succeed())
+
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "late"})
+			Expect(testSink.LogMessages()).To(BeEmpty())
+			Expect(sink.Dropped()).To(Equal(uint64(1)))
+		})
+
+		It("closes the wrapped sink once", func() {
+			lifecycle := &lifecycleSink{err: errors.New("boom")}
+			async := lager.NewAsyncSink(lifecycle, 2, lager.OverflowBlock, lager.DEBUG)
+
+			Expect(async.Close()).To(MatchError("boom"))
+			Expect(async.Close()).To(MatchError("boom"))
+			Expect(lifecycle.closes).To(Equal(1))
+		})
+
+		It("releases writers blocked on a full queue", func() {
+			fill()
+
+			written := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "blocked"})
+				close(written)
+			}()
+			Consistently(written).ShouldNot(BeClosed())
+
+			closed := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(sink.Close()).To(Succeed())
+				close(closed)
+			}()
+
+			gated.open()
+			Eventually(written).Should(BeClosed())
+			Eventually(closed).Should(BeClosed())
+			Expect(testSink.LogMessages()).To(ContainElement("blocked"))
+		})
+	})
+})
@@ -76,21 +76,176 @@ func entry(raw []byte) (entry Entry) {
 	}
 
 	rawString := string(raw)
+
+	prettyLog, ok := decodeJSON(rawString)
+	if !ok {
+		prettyLog, ok = decodeLogfmt(rawString)
+	}
+	if !ok {
+		return
+	}
+
+	entry.Log, entry.IsLager = convertPrettyLog(prettyLog)
+
+	return
+}
+
+func decodeJSON(rawString string) (prettyFormat, bool) {
 	idx := strings.Index(rawString, "{")
 	if idx == -1 {
-		return
+		return prettyFormat{}, false
 	}
 
 	var prettyLog prettyFormat
 	decoder := json.NewDecoder(strings.NewReader(rawString[idx:]))
 	err := decoder.Decode(&prettyLog)
 	if err != nil {
-		return
+		return prettyFormat{}, false
 	}
 
-	entry.Log, entry.IsLager = convertPrettyLog(prettyLog)
+	return prettyLog, true
+}
 
-	return
+// decodeLogfmt reads lines written by lager.NewLogfmtSink and
+// lager.NewPrettyLogfmtSink. Nested data stays flattened under its dotted
+// keys, and unquoted values that look like numbers or booleans are read as
+// such, like they would be from JSON.
+func decodeLogfmt(rawString string) (prettyFormat, bool) {
+	idx := strings.Index(rawString, "timestamp=")
+	if idx == -1 {
+		return prettyFormat{}, false
+	}
+
+	pairs, err := parseLogfmt(rawString[idx:])
+	if err != nil {
+		return prettyFormat{}, false
+	}
+
+	// the fixed fields are only read where the sinks write them, so data
+	// with the same keys stays data
+	pretty := len(pairs) > 1 && pairs[1].key == "level"
+	fields := []string{"timestamp", "source", "message", "log_level"}
+	if pretty {
+		fields = []string{"timestamp", "level", "source", "message"}
+	}
+	if len(pairs) < len(fields) {
+		return prettyFormat{}, false
+	}
+
+	prettyLog := prettyFormat{Data: lager.Data{}}
+	for i, field := range fields {
+		p := pairs[i]
+		if p.key != field {
+			return prettyFormat{}, false
+		}
+
+		switch field {
+		case "timestamp":
+			prettyLog.Timestamp = p.value
+		case "level":
+			prettyLog.Level = p.value
+		case "log_level":
+			err := prettyLog.LogLevel.UnmarshalJSON([]byte(p.value))
+			if err != nil {
+				return prettyFormat{}, false
+			}
+		case "source":
+			prettyLog.Source = p.value
+		case "message":
+			prettyLog.Message = p.value
+		}
+	}
+
+	for _, p := range pairs[len(fields):] {
+		prettyLog.Data[p.key] = logfmtValue(p)
+	}
+
+	return prettyLog, true
+}
+
+func logfmtValue(p logfmtPair) interface{} {
+	if p.quoted {
+		return p.value
+	}
+
+	switch p.value {
+	case "":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if f, err := strconv.ParseFloat(p.value, 64); err == nil {
+		return f
+	}
+	return p.value
+}
+
+type logfmtPair struct {
+	key    string
+	value  string
+	quoted bool
+}
+
+var errInvalidLogfmt = errors.New("invalid logfmt")
+
+// parseLogfmt splits a line into its keys and values, in the order they
+// appear.
+func parseLogfmt(line string) ([]logfmtPair, error) {
+	var pairs []logfmtPair
+
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, errInvalidLogfmt
+		}
+
+		if i == len(line) || line[i] == ' ' {
+			pairs = append(pairs, logfmtPair{key: key})
+			continue
+		}
+		i++ // skip '='
+
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, errInvalidLogfmt
+			}
+
+			value, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, errInvalidLogfmt
+			}
+			pairs = append(pairs, logfmtPair{key: key, value: value, quoted: true})
+			i = end + 1
+			continue
+		}
+
+		start = i
+		for i < len(line) && line[i] != ' ' {
+			i++
+		}
+		pairs = append(pairs, logfmtPair{key: key, value: line[start:i]})
+	}
+
+	return pairs, nil
 }
 
 func convertPrettyLog(lagerLog prettyFormat) (LogEntry, bool) {
@@ -168,12 +323,40 @@ func sessionFromData(data lager.Data) (string, error) {
 func errorFromData(data lager.Data) (error, error) {
 	err, ok := data["error"]
 	if ok {
-		errorString, ok := err.(string)
-		if !ok {
+		switch e := err.(type) {
+		case string:
+			delete(data, "error")
+			return errors.New(e), nil
+		case map[string]interface{}:
+			details, convErr := errorDetailsFromMap(e)
+			if convErr != nil {
+				return nil, convErr
+			}
+			delete(data, "error")
+			return details, nil
+		default:
 			return nil, fmt.Errorf("unable to convert error: %v", err)
 		}
-		delete(data, "error")
-		return errors.New(errorString), nil
 	}
 	return nil, nil
 }
+
+// errorDetailsFromMap reads back the error object written by loggers with
+// lager.LoggerConfig.ErrorDetails set.
+func errorDetailsFromMap(m map[string]interface{}) (*lager.ErrorDetails, error) {
+	if _, ok := m["message"].(string); !ok {
+		return nil, fmt.Errorf("unable to convert error: %v", m)
+	}
+
+	encoded, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var details lager.ErrorDetails
+	err = json.Unmarshal(encoded, &details)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert error: %v", m)
+	}
+	return &details, nil
+}
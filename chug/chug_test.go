@@ -3,6 +3,7 @@ package chug_test
 import (
 	"code.cloudfoundry.org/lager/v3/chug"
 	"errors"
+	"fmt"
 	"io"
 	"time"
 
@@ -82,6 +83,45 @@ var _ = Describe("Chug", func() {
 			})
 		})
 
+		Context("when parsing an error message with error details", func() {
+			BeforeEach(func() {
+				logger = lager.NewLoggerWithConfig("chug-test", lager.LoggerConfig{ErrorDetails: true})
+				logger.RegisterSink(lager.NewWriterSink(pipeWriter, lager.DEBUG))
+			})
+
+			It("should read the error back as lager.ErrorDetails", func() {
+				err := fmt.Errorf("some-context: %w", errors.New("some-error"))
+				logger.Error("chug", err, lager.Data{"some-string": "foo"})
+
+				entry := <-stream
+				Expect(entry.IsLager).To(BeTrue())
+				Expect(entry.Log.Data).To(Equal(lager.Data{"some-string": "foo"}))
+
+				var details *lager.ErrorDetails
+				Expect(errors.As(entry.Log.Error, &details)).To(BeTrue())
+				Expect(details.Message).To(Equal("some-context: some-error"))
+				Expect(details.Type).To(Equal("*fmt.wrapError"))
+				Expect(details.Causes).To(ConsistOf(lager.ErrorDetails{
+					Message: "some-error",
+					Type:    "*errors.errorString",
+				}))
+			})
+		})
+
+		Context("when parsing a warn message", func() {
+			It("should round-trip the level", func() {
+				data := lager.Data{"some-float": 3.0, "some-string": "foo", "error": "some-error"}
+				logger.Warn("chug", data)
+				Expect((<-stream).Log).To(MatchLogEntry(chug.LogEntry{
+					LogLevel: lager.WARN,
+					Source:   "chug-test",
+					Message:  "chug-test.chug",
+					Error:    nil,
+					Data:     lager.Data{"some-float": 3.0, "some-string": "foo", "error": "some-error"},
+				}))
+			})
+		})
+
 		Context("when parsing an info message with an error", func() {
 			It("should not take the error out of the data map", func() {
 				data := lager.Data{"some-float": 3.0, "some-string": "foo", "error": "some-error"}
@@ -151,6 +191,116 @@ var _ = Describe("Chug", func() {
 			})
 		})
 
+		Context("when the input is logfmt", func() {
+			BeforeEach(func() {
+				logger = lager.NewLogger("chug-test")
+				logger.RegisterSink(lager.NewLogfmtSink(pipeWriter, lager.DEBUG))
+			})
+
+			It("should return parsed lager messages", func() {
+				logger.Session("session").Info("again", lager.Data{
+					"some-float":  3.0,
+					"some-string": "foo bar",
+					"number-ish":  "42",
+					"nested":      lager.Data{"flag": true},
+					"source":      "clashes with a field",
+				})
+
+				entry := <-stream
+				Expect(entry.IsLager).To(BeTrue())
+				Expect(entry.Log).To(MatchLogEntry(chug.LogEntry{
+					LogLevel: lager.INFO,
+					Source:   "chug-test",
+					Message:  "chug-test.session.again",
+					Session:  "1",
+					Data: lager.Data{
+						"some-float":  3.0,
+						"some-string": "foo bar",
+						"number-ish":  "42",
+						"nested.flag": true,
+						"source":      "clashes with a field",
+					},
+				}))
+			})
+
+			It("reads data keys named like the fixed fields as data", func() {
+				data := lager.Data{
+					"level":     "error",
+					"log_level": "high",
+					"source":    "elsewhere",
+					"message":   "not this one",
+				}
+				logger.Info("again", data)
+
+				entry := <-stream
+				Expect(entry.IsLager).To(BeTrue())
+				Expect(entry.Log).To(MatchLogEntry(chug.LogEntry{
+					LogLevel: lager.INFO,
+					Source:   "chug-test",
+					Message:  "chug-test.again",
+					Data:     data,
+				}))
+			})
+
+			It("should include the error", func() {
+				logger.Error("chug", errors.New("some-error"))
+
+				Expect((<-stream).Log).To(MatchLogEntry(chug.LogEntry{
+					LogLevel: lager.ERROR,
+					Source:   "chug-test",
+					Message:  "chug-test.chug",
+					Error:    errors.New("some-error"),
+					Data:     lager.Data{},
+				}))
+			})
+
+			It("should parse the timestamp", func() {
+				logger.Debug("chug")
+				entry := <-stream
+				Expect(entry.Log.Timestamp).To(BeTemporally("~", time.Now(), time.Second))
+			})
+
+			Context("with human readable timestamps", func() {
+				BeforeEach(func() {
+					logger = lager.NewLogger("chug-test")
+					logger.RegisterSink(lager.NewPrettyLogfmtSink(pipeWriter, lager.DEBUG))
+				})
+
+				It("should return parsed lager messages", func() {
+					logger.Warn("careful", lager.Data{"some-string": "foo"})
+
+					entry := <-stream
+					Expect(entry.IsLager).To(BeTrue())
+					Expect(entry.Log).To(MatchLogEntry(chug.LogEntry{
+						LogLevel: lager.WARN,
+						Source:   "chug-test",
+						Message:  "chug-test.careful",
+						Data:     lager.Data{"some-string": "foo"},
+					}))
+					Expect(entry.Log.Timestamp).To(BeTemporally("~", time.Now(), time.Second))
+				})
+
+				It("reads data keys named like the fixed fields as data", func() {
+					data := lager.Data{
+						"level":     "high",
+						"log_level": "error",
+						"source":    "elsewhere",
+						"message":   "not this one",
+					}
+					logger.Info("again", data)
+
+					entry := <-stream
+					Expect(entry.IsLager).To(BeTrue())
+					Expect(entry.Log).To(MatchLogEntry(chug.LogEntry{
+						LogLevel: lager.INFO,
+						Source:   "chug-test",
+						Message:  "chug-test.again",
+						Data:     data,
+					}))
+				})
+			})
+		})
+
 		Context("when the input is formatted with human readable timestamps", func() {
 			BeforeEach(func() {
 				logger = lager.NewLogger("chug-test")
@@ -161,6 +311,7 @@ var _ = Describe("Chug", func() {
 				data := lager.Data{"some-float": 3.0, "some-string": "foo"}
 				logger.Debug("chug", data)
 				logger.Info("again", data)
+				logger.Warn("careful", data)
 
 				entry := <-stream
 				Expect(entry.IsLager).To(BeTrue())
@@ -179,6 +330,15 @@ var _ = Describe("Chug", func() {
 					Message:  "chug-test.again",
 					Data:     data,
 				}))
+
+				entry = <-stream
+				Expect(entry.IsLager).To(BeTrue())
+				Expect(entry.Log).To(MatchLogEntry(chug.LogEntry{
+					LogLevel: lager.WARN,
+					Source:   "chug-test",
+					Message:  "chug-test.careful",
+					Data:     data,
+				}))
 			})
 		})
 	})
@@ -210,6 +370,19 @@ var _ = Describe("Chug", func() {
 		})
 	})
 
+	Context("handling an error logged by an older version of lager", func() {
+		It("reads the error level and the error", func() {
+			_, err := pipeWriter.Write([]byte(`{"timestamp":"1407102779.028711081","source":"chug-test","message":"chug-test.chug","log_level":2,"data":{"error":"some-error"}}` + "\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			var entry chug.Entry
+			Eventually(stream).Should(Receive(&entry))
+			Expect(entry.IsLager).To(BeTrue())
+			Expect(entry.Log.LogLevel).To(Equal(lager.ERROR))
+			Expect(entry.Log.Error).To(MatchError("some-error"))
+		})
+	})
+
 	Context("handling malformed/non-lager data", func() {
 		var input []byte
 		var entry chug.Entry
@@ -265,6 +438,28 @@ var _ = Describe("Chug", func() {
 			itReturnsRawData(entry, input)
 		})
 
+		Context("when fed logfmt with an unterminated quote", func() {
+			BeforeEach(func() {
+				input = []byte(`timestamp=1407102779.028711081 source=chug-test message="chug-test.chug log_level=1`)
+			})
+
+			It("returns raw data", func() {
+				Expect(entry.IsLager).To(BeFalse())
+				Expect(entry.Raw).To(Equal(input))
+			})
+		})
+
+		Context("when fed logfmt that is not a lager message", func() {
+			BeforeEach(func() {
+				input = []byte(`timestamp=1407102779.028711081 msg=hello`)
+			})
+
+			It("returns raw data", func() {
+				Expect(entry.IsLager).To(BeFalse())
+				Expect(entry.Raw).To(Equal(input))
+			})
+		})
+
 		Context("When fed none-JSON that is not a lager message at all", func() {
 			BeforeEach(func() {
 				input = []byte(`ß`)
@@ -0,0 +1,203 @@
+package lager
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ColorMode decides whether a console sink colors its output.
+type ColorMode int
+
+const (
+	// ColorAuto colors the output when the writer is a terminal.
+	ColorAuto ColorMode = iota
+	// ColorAlways colors the output.
+	ColorAlways
+	// ColorNever never colors the output.
+	ColorNever
+)
+
+const (
+	consoleTimeFormat   = "15:04:05.000"
+	consoleMessageWidth = 40
+
+	colorReset  = "\x1b[0m"
+	colorFaint  = "\x1b[2m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorBlue   = "\x1b[34m"
+	colorCyan   = "\x1b[36m"
+	colorBold   = "\x1b[1m"
+)
+
+// ConsoleSinkConfig configures a sink created by NewConsoleSink.
+type ConsoleSinkConfig struct {
+	MinLogLevel LogLevel
+	Color       ColorMode
+	// MultilineTraces prints the stack trace that Fatal adds under "trace"
+	// on the lines following the log, instead of as a single quoted value.
+	MultilineTraces bool
+}
+
+type consoleSink struct {
+	writer io.Writer
+	config ConsoleSinkConfig
+	color  bool
+	writeL sync.Mutex
+}
+
+// NewConsoleSink returns a sink that writes each log as an aligned line of
+// text for people to read during local development, e.g.
+//
+//	12:04:05.123 INFO  my-app.my-task.my-action               key=value
+//
+// Use NewWriterSink for logs that are read by other programs.
+func NewConsoleSink(writer io.Writer, config ConsoleSinkConfig) Sink {
+	color := config.Color == ColorAlways
+	if config.Color == ColorAuto {
+		color = isTerminal(writer)
+	}
+
+	return &consoleSink{
+		writer: writer,
+		config: config,
+		color:  color,
+	}
+}
+
+func (sink *consoleSink) Log(log LogFormat) {
+	if log.LogLevel < sink.config.MinLogLevel {
+		return
+	}
+
+	// Format outside of critical section to minimize time spent holding lock
+	buf := getBuffer()
+	*buf = sink.appendLine(*buf, log)
+
+	sink.writeL.Lock()
+	sink.writer.Write(*buf) //nolint:errcheck
+	sink.writeL.Unlock()
+
+	putBuffer(buf)
+}
+
+func (sink *consoleSink) Enabled(level LogLevel) bool {
+	return level >= sink.config.MinLogLevel
+}
+
+func (sink *consoleSink) appendLine(dst []byte, log LogFormat) []byte {
+	t := log.time
+	if t.IsZero() {
+		t = parseTimestamp(log.Timestamp)
+	}
+
+	var timeBuf [len(consoleTimeFormat)]byte
+	dst = sink.appendColored(dst, colorFaint, t.Local().AppendFormat(timeBuf[:0], consoleTimeFormat))
+	dst = append(dst, ' ')
+
+	level := strings.ToUpper(log.LogLevel.String())
+	dst = sink.appendColored(dst, levelColor(log.LogLevel), []byte(level))
+	for i := len(level); i < len("DEBUG")+1; i++ {
+		dst = append(dst, ' ')
+	}
+
+	dst = append(dst, log.Message...)
+
+	var trace string
+	if sink.config.MultilineTraces {
+		trace, _ = log.Data["trace"].(string)
+	}
+
+	keys := make([]string, 0, len(log.Data))
+	for k := range log.Data {
+		if trace != "" && k == "trace" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+
+	if len(keys) > 0 {
+		for i := utf8.RuneCountInString(log.Message); i < consoleMessageWidth; i++ {
+			dst = append(dst, ' ')
+		}
+	}
+
+	for _, k := range keys {
+		dst = append(dst, ' ')
+		dst = sink.appendColored(dst, colorCyan, []byte(k))
+		dst = append(dst, '=')
+		dst = appendConsoleValue(dst, log.Data[k])
+	}
+	dst = append(dst, '\n')
+
+	if trace != "" {
+		for _, line := range strings.Split(strings.TrimRight(trace, "\n"), "\n") {
+			dst = append(dst, "    "...)
+			dst = sink.appendColored(dst, colorFaint, []byte(line))
+			dst = append(dst, '\n')
+		}
+	}
+
+	return dst
+}
+
+func (sink *consoleSink) appendColored(dst []byte, color string, s []byte) []byte {
+	if !sink.color {
+		return append(dst, s...)
+	}
+	dst = append(dst, color...)
+	dst = append(dst, s...)
+	return append(dst, colorReset...)
+}
+
+func levelColor(level LogLevel) string {
+	switch level {
+	case DEBUG:
+		return colorBlue
+	case INFO:
+		return colorGreen
+	case WARN:
+		return colorYellow
+	case ERROR:
+		return colorRed
+	default:
+		return colorBold + colorRed
+	}
+}
+
+// appendConsoleValue writes strings as they are unless they need quoting to
+// keep the line unambiguous, and everything else as JSON.
+func appendConsoleValue(dst []byte, v interface{}) []byte {
+	if s, ok := v.(string); ok {
+		if s != "" && !strings.ContainsAny(s, " =\"\\\t\r\n") {
+			return append(dst, s...)
+		}
+		return appendJSONString(dst, s)
+	}
+
+	out, err := appendJSONValue(dst, v)
+	if err != nil {
+		return append(dst, "<"+err.Error()+">"...)
+	}
+	return out
+}
+
+// isTerminal reports whether the writer is a character device such as a
+// terminal, rather than a file or pipe.
+func isTerminal(writer io.Writer) bool {
+	f, ok := writer.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,144 @@
+package lager_test
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("ConsoleSink", func() {
+	var (
+		buffer *gbytes.Buffer
+		config lager.ConsoleSinkConfig
+		sink   lager.Sink
+		logger lager.Logger
+	)
+
+	BeforeEach(func() {
+		buffer = gbytes.NewBuffer()
+		config = lager.ConsoleSinkConfig{MinLogLevel: lager.INFO}
+	})
+
+	JustBeforeEach(func() {
+		sink = lager.NewConsoleSink(buffer, config)
+		logger = lager.NewLogger("my-app")
+		logger.RegisterSink(sink)
+	})
+
+	It("writes the time, level, message and data on one line", func() {
+		logger.Session("task").Info("did-something", lager.Data{"count": 3, "name": "foo"})
+
+		Expect(string(buffer.Contents())).To(MatchRegexp(
+			`^\d\d:\d\d:\d\d\.\d{3} INFO  my-app\.task\.did-something {16}count=3 name=foo session=1\n$`,
+		))
+	})
+
+	It("writes the timestamp in local time", func() {
+		t := time.Date(2024, 3, 1, 12, 34, 56, 789000000, time.UTC)
+		sink.Log(lager.LogFormat{
+			Timestamp: "1709296496.789000000",
+			Message:   "my-app.did-something",
+			LogLevel:  lager.WARN,
+		})
+
+		Expect(string(buffer.Contents())).To(HavePrefix(t.Local().Format("15:04:05.000") + " WARN  my-app.did-something\n"))
+	})
+
+	It("quotes strings that would make the line ambiguous", func() {
+		logger.Info("did-something", lager.Data{
+			"empty":  "",
+			"spaces": "hello world",
+			"equals": "a=b",
+			"quotes": `say "hi"`,
+		})
+
+		Expect(buffer).To(gbytes.Say(`empty="" equals="a=b" quotes="say \\"hi\\"" spaces="hello world"\n`))
+	})
+
+	It("writes other values as JSON", func() {
+		logger.Error("failed", errors.New("boom"), lager.Data{
+			"nested": map[string]interface{}{"a": 1},
+			"ok":     true,
+			"list":   []interface{}{"x", 2},
+		})
+
+		Expect(buffer).To(gbytes.Say(`ERROR my-app\.failed +error=boom list=\["x",2\] nested={"a":1} ok=true\n`))
+	})
+
+	It("does not write logs below the minimum level", func() {
+		logger.Debug("hidden")
+		Expect(buffer.Contents()).To(BeEmpty())
+		Expect(lager.SinkEnabled(sink, lager.DEBUG)).To(BeFalse())
+		Expect(lager.SinkEnabled(sink, lager.INFO)).To(BeTrue())
+	})
+
+	It("does not color output to writers that are not terminals", func() {
+		logger.Info("did-something")
+		Expect(string(buffer.Contents())).NotTo(ContainSubstring("\x1b["))
+	})
+
+	It("does not color output to files", func() {
+		f, err := os.CreateTemp("", "console-sink")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		fileLogger := lager.NewLogger("my-app")
+		fileLogger.RegisterSink(lager.NewConsoleSink(f, lager.ConsoleSinkConfig{}))
+		fileLogger.Info("did-something")
+
+		contents, err := os.ReadFile(f.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(ContainSubstring("INFO  my-app.did-something"))
+		Expect(string(contents)).NotTo(ContainSubstring("\x1b["))
+	})
+
+	Context("with ColorAlways", func() {
+		BeforeEach(func() {
+			config.Color = lager.ColorAlways
+		})
+
+		It("colors the level", func() {
+			logger.Warn("careful")
+			Expect(string(buffer.Contents())).To(ContainSubstring("\x1b[33mWARN\x1b[0m"))
+		})
+	})
+
+	Context("with MultilineTraces", func() {
+		BeforeEach(func() {
+			config.MultilineTraces = true
+		})
+
+		It("writes the stack trace from Fatal on the following lines", func() {
+			func() {
+				defer func() {
+					recover() //nolint:errcheck
+				}()
+				logger.Fatal("crashed", errors.New("boom"))
+			}()
+
+			Expect(buffer).To(gbytes.Say(`FATAL my-app\.crashed +error=boom\n`))
+			Expect(buffer).To(gbytes.Say(`    goroutine \d+ \[running\]:\n`))
+			Expect(string(buffer.Contents())).NotTo(ContainSubstring("trace="))
+		})
+	})
+
+	Context("without MultilineTraces", func() {
+		It("writes the stack trace as a quoted value", func() {
+			func() {
+				defer func() {
+					recover() //nolint:errcheck
+				}()
+				logger.Fatal("crashed", errors.New("boom"))
+			}()
+
+			Expect(buffer).To(gbytes.Say(`error=boom trace="goroutine \d+ \[running\]:\\n`))
+		})
+	})
+})
@@ -0,0 +1,102 @@
+package lager
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// maxErrorDepth bounds how far NewErrorDetails follows wrapped errors.
+const maxErrorDepth = 32
+
+// ErrorDetails is the structured form of an error that a logger created with
+// LoggerConfig.ErrorDetails records under the "error" key. It is itself an
+// error, so that it can stand in for the original when read back by chug.
+type ErrorDetails struct {
+	Message string `json:"message"`
+	// Type is the Go type of the error, e.g. "*fs.PathError".
+	Type string `json:"type"`
+	// Data holds the fields of errors that implement DataError.
+	Data Data `json:"data,omitempty"`
+	// Stack is the stack trace of errors that carry one, such as those
+	// created by github.com/pkg/errors.
+	Stack string `json:"stack,omitempty"`
+	// Causes are the errors returned by Unwrap. There is more than one for
+	// errors created by errors.Join.
+	Causes []ErrorDetails `json:"causes,omitempty"`
+}
+
+// DataError is implemented by errors that have fields worth logging
+// separately from their message.
+type DataError interface {
+	error
+	LagerData() Data
+}
+
+// NewErrorDetails records err and every error it wraps.
+func NewErrorDetails(err error) ErrorDetails {
+	return newErrorDetails(err, 0)
+}
+
+func newErrorDetails(err error, depth int) ErrorDetails {
+	details := ErrorDetails{
+		Message: err.Error(),
+		Type:    fmt.Sprintf("%T", err),
+		Stack:   stackTraceOf(err),
+	}
+
+	if dataErr, ok := err.(DataError); ok {
+		details.Data = dataErr.LagerData()
+	}
+
+	if depth >= maxErrorDepth {
+		return details
+	}
+
+	var causes []error
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if cause := e.Unwrap(); cause != nil {
+			causes = []error{cause}
+		}
+	case interface{ Unwrap() []error }:
+		causes = e.Unwrap()
+	}
+
+	for _, cause := range causes {
+		if cause != nil {
+			details.Causes = append(details.Causes, newErrorDetails(cause, depth+1))
+		}
+	}
+
+	return details
+}
+
+// stackTraceOf formats the result of the error's StackTrace method, if it has
+// one. Libraries each return their own type from StackTrace, so the method is
+// found by name and its result formatted with %+v, which is how
+// github.com/pkg/errors prints function names and file paths.
+func stackTraceOf(err error) string {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return ""
+	}
+
+	return fmt.Sprintf("%+v", method.Call(nil)[0].Interface())
+}
+
+func (e *ErrorDetails) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the causes, so that errors.As can find them.
+func (e *ErrorDetails) Unwrap() []error {
+	if len(e.Causes) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(e.Causes))
+	for i := range e.Causes {
+		errs[i] = &e.Causes[i]
+	}
+	return errs
+}
@@ -0,0 +1,87 @@
+package lager_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type stackTrace []string
+
+func (st stackTrace) Format(f fmt.State, verb rune) {
+	for _, frame := range st {
+		fmt.Fprintf(f, "\n%s", frame)
+	}
+}
+
+type stackError struct {
+	msg   string
+	stack stackTrace
+}
+
+func (e *stackError) Error() string          { return e.msg }
+func (e *stackError) StackTrace() stackTrace { return e.stack }
+
+type quotaError struct {
+	limit int
+}
+
+func (e quotaError) Error() string         { return "quota exceeded" }
+func (e quotaError) LagerData() lager.Data { return lager.Data{"limit": e.limit} }
+
+var _ = Describe("ErrorDetails", func() {
+	It("records the message and type", func() {
+		details := lager.NewErrorDetails(os.ErrNotExist)
+		Expect(details).To(Equal(lager.ErrorDetails{
+			Message: "file does not exist",
+			Type:    "*errors.errorString",
+		}))
+	})
+
+	It("follows the unwrap chain", func() {
+		_, err := os.Open("/does/not/exist")
+		details := lager.NewErrorDetails(fmt.Errorf("loading config: %w", err))
+
+		Expect(details.Type).To(Equal("*fmt.wrapError"))
+		Expect(details.Causes).To(HaveLen(1))
+		Expect(details.Causes[0].Type).To(Equal("*fs.PathError"))
+		Expect(details.Causes[0].Causes).To(HaveLen(1))
+		Expect(details.Causes[0].Causes[0].Type).To(Equal("syscall.Errno"))
+	})
+
+	It("records every error of a joined error", func() {
+		details := lager.NewErrorDetails(errors.Join(errors.New("first"), errors.New("second")))
+
+		Expect(details.Message).To(Equal("first\nsecond"))
+		Expect(details.Causes).To(HaveLen(2))
+		Expect(details.Causes[0].Message).To(Equal("first"))
+		Expect(details.Causes[1].Message).To(Equal("second"))
+	})
+
+	It("records the data of errors that implement DataError", func() {
+		details := lager.NewErrorDetails(fmt.Errorf("uploading: %w", quotaError{limit: 10}))
+
+		Expect(details.Data).To(BeNil())
+		Expect(details.Causes[0].Data).To(Equal(lager.Data{"limit": 10}))
+	})
+
+	It("records the stack trace of errors that carry one", func() {
+		err := &stackError{msg: "boom", stack: stackTrace{"main.main", "runtime.main"}}
+		details := lager.NewErrorDetails(err)
+
+		Expect(details.Stack).To(Equal("\nmain.main\nruntime.main"))
+	})
+
+	It("unwraps to the causes", func() {
+		details := lager.NewErrorDetails(fmt.Errorf("outer: %w", errors.New("inner")))
+
+		causes := details.Unwrap()
+		Expect(causes).To(HaveLen(1))
+		Expect(causes[0].Error()).To(Equal("inner"))
+	})
+})
@@ -0,0 +1,404 @@
+package lager
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000000000"
+
+// FileFormat decides how a FileSink writes each log.
+type FileFormat int
+
+const (
+	// FileFormatJSON writes logs like NewWriterSink, or NewPrettySink when
+	// Pretty is set.
+	FileFormatJSON FileFormat = iota
+	// FileFormatLogfmt writes logs like NewLogfmtSink, or
+	// NewPrettyLogfmtSink when Pretty is set.
+	FileFormatLogfmt
+	// FileFormatConsole writes logs like NewConsoleSink, without color.
+	FileFormatConsole
+)
+
+// FileSinkConfig describes where a FileSink writes and when it rotates.
+type FileSinkConfig struct {
+	// Path of the active log file. Rotated files are written next to it as
+	// Path.<timestamp>, with a .gz suffix when Compress is set.
+	Path        string
+	MinLogLevel LogLevel
+
+	// Format defaults to FileFormatJSON.
+	Format FileFormat
+	// Pretty writes RFC3339 timestamps and level names, as NewPrettySink
+	// does, in the JSON and logfmt formats.
+	Pretty bool
+
+	// MaxSize rotates the file before a write would take it past this many
+	// bytes. Zero disables size based rotation.
+	MaxSize int64
+	// RotateInterval rotates the file on wall-clock boundaries of this
+	// interval, e.g. on the hour for time.Hour. Zero disables time based
+	// rotation.
+	RotateInterval time.Duration
+	// MaxBackups is the number of rotated files to keep. Zero keeps them all.
+	MaxBackups int
+	// Compress gzips rotated files.
+	Compress bool
+
+	// ReopenOnSIGHUP reopens Path whenever the process receives SIGHUP, for
+	// use with external tools such as logrotate.
+	ReopenOnSIGHUP bool
+}
+
+// FileSink is a Sink that writes to a file and optionally rotates it.
+type FileSink struct {
+	config  FileSinkConfig
+	console *consoleSink
+
+	writeL       sync.Mutex
+	file         *os.File
+	size         int64
+	nextRotation time.Time
+	closed       bool
+	// err is the first failure to open or write to the file since the last
+	// Flush, which returns it
+	err error
+
+	cleanupL sync.Mutex
+	cleanup  sync.WaitGroup
+
+	signals chan os.Signal
+	done    chan struct{}
+}
+
+// NewFileSink opens (or creates) the file at config.Path for appending.
+func NewFileSink(config FileSinkConfig) (*FileSink, error) {
+	sink := &FileSink{
+		config:  config,
+		console: &consoleSink{config: ConsoleSinkConfig{MinLogLevel: config.MinLogLevel}},
+		done:    make(chan struct{}),
+	}
+
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+
+	if config.ReopenOnSIGHUP {
+		sink.signals = make(chan os.Signal, 1)
+		signal.Notify(sink.signals, syscall.SIGHUP)
+		go sink.reopenOnSignal()
+	}
+
+	return sink, nil
+}
+
+func (sink *FileSink) Log(log LogFormat) {
+	if log.LogLevel < sink.config.MinLogLevel {
+		return
+	}
+
+	// Format outside of critical section to minimize time spent holding lock
+	buf := getBuffer()
+	defer putBuffer(buf)
+	*buf = sink.appendLog(*buf, log)
+
+	sink.writeL.Lock()
+	defer sink.writeL.Unlock()
+
+	if sink.closed {
+		return
+	}
+
+	// A failed rotation or reopen leaves no file, so try again
+	if sink.file == nil {
+		if err := sink.open(); err != nil {
+			sink.fail(err)
+			return
+		}
+	}
+
+	if sink.shouldRotate(int64(len(*buf))) {
+		if err := sink.rotate(); err != nil {
+			sink.fail(err)
+			if sink.file == nil {
+				return
+			}
+		}
+	}
+
+	n, err := sink.file.Write(*buf)
+	sink.size += int64(n)
+	if err != nil {
+		sink.fail(err)
+	}
+}
+
+// fail records err for Flush to return, unless an earlier failure is already
+// recorded. The caller must hold writeL.
+func (sink *FileSink) fail(err error) {
+	if sink.err == nil {
+		sink.err = err
+	}
+}
+
+// appendLog formats the log as a line in the configured format.
+func (sink *FileSink) appendLog(dst []byte, log LogFormat) []byte {
+	switch {
+	case sink.config.Format == FileFormatLogfmt:
+		return append(log.appendLogfmt(dst, sink.config.Pretty), '\n')
+	case sink.config.Format == FileFormatConsole:
+		return sink.console.appendLine(dst, log)
+	case sink.config.Pretty:
+		return append(log.appendPrettyJSON(dst), '\n')
+	default:
+		return append(log.appendJSON(dst), '\n')
+	}
+}
+
+func (sink *FileSink) Enabled(level LogLevel) bool {
+	return level >= sink.config.MinLogLevel
+}
+
+// Rotate moves the current file aside and starts a new one.
+func (sink *FileSink) Rotate() error {
+	sink.writeL.Lock()
+	defer sink.writeL.Unlock()
+
+	if sink.closed {
+		return os.ErrClosed
+	}
+
+	return sink.rotate()
+}
+
+// Reopen closes and reopens the file at the configured path. Use it after the
+// file has been moved by an external tool. If the file cannot be opened, logs
+// are dropped until a later write manages to open it.
+func (sink *FileSink) Reopen() error {
+	sink.writeL.Lock()
+	defer sink.writeL.Unlock()
+
+	if sink.closed {
+		return os.ErrClosed
+	}
+
+	if sink.file != nil {
+		sink.file.Close() //nolint:errcheck
+		sink.file = nil
+	}
+	return sink.open()
+}
+
+// Flush commits the file's contents to stable storage. It returns the first
+// error met opening or writing to the file since the last Flush, so that logs
+// dropped because of it are reported.
+func (sink *FileSink) Flush() error {
+	sink.writeL.Lock()
+	defer sink.writeL.Unlock()
+
+	if sink.closed {
+		return nil
+	}
+
+	err := sink.err
+	sink.err = nil
+
+	if sink.file == nil {
+		if openErr := sink.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+	}
+
+	return errors.Join(err, sink.file.Sync())
+}
+
+// Close closes the file and waits for any pending compression to finish.
+// Logs written after Close are discarded.
+func (sink *FileSink) Close() error {
+	sink.writeL.Lock()
+	if sink.closed {
+		sink.writeL.Unlock()
+		return nil
+	}
+	sink.closed = true
+	var err error
+	if sink.file != nil {
+		err = sink.file.Close()
+		sink.file = nil
+	}
+	sink.writeL.Unlock()
+
+	if sink.signals != nil {
+		signal.Stop(sink.signals)
+	}
+	close(sink.done)
+	sink.cleanup.Wait()
+
+	return err
+}
+
+func (sink *FileSink) reopenOnSignal() {
+	for {
+		select {
+		case <-sink.signals:
+			sink.Reopen() //nolint:errcheck
+		case <-sink.done:
+			return
+		}
+	}
+}
+
+// open opens the file at the configured path. The caller must hold writeL.
+func (sink *FileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(sink.config.Path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(sink.config.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close() //nolint:errcheck
+		return err
+	}
+
+	sink.file = file
+	sink.size = info.Size()
+	if sink.config.RotateInterval > 0 {
+		sink.nextRotation = time.Now().Truncate(sink.config.RotateInterval).Add(sink.config.RotateInterval)
+	}
+
+	return nil
+}
+
+// shouldRotate reports whether the file needs rotating before a write of n
+// bytes. The caller must hold writeL.
+func (sink *FileSink) shouldRotate(n int64) bool {
+	if sink.config.MaxSize > 0 && sink.size > 0 && sink.size+n > sink.config.MaxSize {
+		return true
+	}
+
+	return sink.config.RotateInterval > 0 && !time.Now().Before(sink.nextRotation)
+}
+
+// rotate renames the current file, opens a new one and hands the old file off
+// for compression and pruning. If the new file cannot be opened the sink is
+// left without one, and the next write tries again. The caller must hold
+// writeL.
+func (sink *FileSink) rotate() error {
+	var closeErr error
+	if sink.file != nil {
+		closeErr = sink.file.Close()
+		sink.file = nil
+	}
+
+	backup := sink.config.Path + "." + time.Now().UTC().Format(backupTimeFormat)
+	renameErr := os.Rename(sink.config.Path, backup)
+
+	// Always reopen so that a failed rename does not leave the sink without a
+	// file to write to
+	if err := sink.open(); err != nil {
+		return errors.Join(closeErr, err)
+	}
+	if renameErr != nil {
+		return errors.Join(closeErr, renameErr)
+	}
+
+	sink.cleanup.Add(1)
+	go func() {
+		defer sink.cleanup.Done()
+
+		sink.cleanupL.Lock()
+		defer sink.cleanupL.Unlock()
+
+		if sink.config.Compress {
+			compressFile(backup) //nolint:errcheck
+		}
+		sink.pruneBackups()
+	}()
+
+	return closeErr
+}
+
+// pruneBackups removes the oldest rotated files beyond MaxBackups.
+func (sink *FileSink) pruneBackups() {
+	if sink.config.MaxBackups <= 0 {
+		return
+	}
+
+	dir := filepath.Dir(sink.config.Path)
+	prefix := filepath.Base(sink.config.Path) + "."
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz")
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, name)
+	}
+
+	if len(backups) <= sink.config.MaxBackups {
+		return
+	}
+
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-sink.config.MaxBackups] {
+		os.Remove(filepath.Join(dir, name)) //nolint:errcheck
+	}
+}
+
+// compressFile gzips the file at path to path.gz and removes the original.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()           //nolint:errcheck
+		os.Remove(out.Name()) //nolint:errcheck
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()           //nolint:errcheck
+		os.Remove(out.Name()) //nolint:errcheck
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name()) //nolint:errcheck
+		return err
+	}
+
+	in.Close() //nolint:errcheck
+	return os.Remove(path)
+}
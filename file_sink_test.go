@@ -0,0 +1,243 @@
+package lager_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FileSink", func() {
+	var (
+		dir    string
+		path   string
+		config lager.FileSinkConfig
+		sink   *lager.FileSink
+	)
+
+	readFile := func(name string) string {
+		contents, err := os.ReadFile(name)
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	backups := func() []string {
+		matches, err := filepath.Glob(path + ".*")
+		Expect(err).NotTo(HaveOccurred())
+		return matches
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		path = filepath.Join(dir, "logs", "component.log")
+		config = lager.FileSinkConfig{
+			Path:        path,
+			MinLogLevel: lager.INFO,
+		}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		sink, err = lager.NewFileSink(config)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(sink.Close()).To(Succeed())
+	})
+
+	It("writes logs at or above the minimum level to the file", func() {
+		sink.Log(lager.LogFormat{LogLevel: lager.DEBUG, Message: "hidden"})
+		log := lager.LogFormat{LogLevel: lager.INFO, Message: "hello world"}
+		sink.Log(log)
+
+		Expect(readFile(path)).To(MatchJSON(log.ToJSON()))
+	})
+
+	It("appends to an existing file", func() {
+		sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "first"})
+		Expect(sink.Close()).To(Succeed())
+
+		var err error
+		sink, err = lager.NewFileSink(config)
+		Expect(err).NotTo(HaveOccurred())
+		sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "second"})
+
+		contents := readFile(path)
+		Expect(contents).To(ContainSubstring("first"))
+		Expect(contents).To(ContainSubstring("second"))
+	})
+
+	It("discards logs written after Close", func() {
+		Expect(sink.Close()).To(Succeed())
+		sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "late"})
+
+		Expect(readFile(path)).To(BeEmpty())
+	})
+
+	Context("when Pretty is set", func() {
+		BeforeEach(func() {
+			config.Pretty = true
+		})
+
+		It("writes logs in the pretty format", func() {
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "hello world", Timestamp: "1464388983.540486336"})
+
+			Expect(readFile(path)).To(ContainSubstring(`"level":"info"`))
+			Expect(readFile(path)).To(ContainSubstring(`"timestamp":"2016-05-27T22:43:03.540486336Z"`))
+		})
+	})
+
+	Context("when Format is FileFormatLogfmt", func() {
+		BeforeEach(func() {
+			config.Format = lager.FileFormatLogfmt
+		})
+
+		It("writes logs in logfmt", func() {
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Source: "test", Message: "test.hello", Timestamp: "1464388983.540486336"})
+			Expect(readFile(path)).To(Equal("timestamp=1464388983.540486336 source=test message=test.hello log_level=1\n"))
+		})
+	})
+
+	Context("when Format is FileFormatConsole", func() {
+		BeforeEach(func() {
+			config.Format = lager.FileFormatConsole
+		})
+
+		It("writes logs in the console format without color", func() {
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "test.hello", Timestamp: "1464388983.540486336", Data: lager.Data{"key": "value"}})
+			Expect(readFile(path)).To(MatchRegexp(`^\d{2}:\d{2}:\d{2}\.\d{3} INFO  test\.hello +key=value\n$`))
+		})
+	})
+
+	Context("when MaxSize is set", func() {
+		BeforeEach(func() {
+			config.MaxSize = 100
+		})
+
+		It("rotates before the file grows past the limit", func() {
+			for i := 0; i < 3; i++ {
+				sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: strings.Repeat("a", 50)})
+			}
+
+			Expect(backups()).To(HaveLen(2))
+			Expect(strings.Count(readFile(path), "\n")).To(Equal(1))
+		})
+	})
+
+	Context("when RotateInterval is set", func() {
+		BeforeEach(func() {
+			config.RotateInterval = 100 * time.Millisecond
+		})
+
+		It("rotates once the interval has passed", func() {
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "before"})
+			time.Sleep(200 * time.Millisecond)
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "after"})
+
+			Expect(backups()).To(HaveLen(1))
+			Expect(readFile(backups()[0])).To(ContainSubstring("before"))
+			Expect(readFile(path)).To(ContainSubstring("after"))
+			Expect(readFile(path)).NotTo(ContainSubstring("before"))
+		})
+	})
+
+	Context("when MaxBackups is set", func() {
+		BeforeEach(func() {
+			config.MaxBackups = 2
+		})
+
+		It("keeps only the newest backups", func() {
+			for _, message := range []string{"one", "two", "three", "four"} {
+				sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: message})
+				Expect(sink.Rotate()).To(Succeed())
+			}
+			Expect(sink.Close()).To(Succeed())
+
+			names := backups()
+			Expect(names).To(HaveLen(2))
+			Expect(readFile(names[0])).To(ContainSubstring("three"))
+			Expect(readFile(names[1])).To(ContainSubstring("four"))
+		})
+	})
+
+	Context("when Compress is set", func() {
+		BeforeEach(func() {
+			config.Compress = true
+		})
+
+		It("gzips rotated files", func() {
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "compressed"})
+			Expect(sink.Rotate()).To(Succeed())
+			Expect(sink.Close()).To(Succeed())
+
+			names := backups()
+			Expect(names).To(HaveLen(1))
+			Expect(names[0]).To(HaveSuffix(".gz"))
+
+			f, err := os.Open(names[0])
+			Expect(err).NotTo(HaveOccurred())
+			defer f.Close()
+			gz, err := gzip.NewReader(f)
+			Expect(err).NotTo(HaveOccurred())
+			contents, err := io.ReadAll(gz)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring("compressed"))
+		})
+	})
+
+	Describe("Reopen", func() {
+		It("starts writing to a new file at the configured path", func() {
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "before"})
+			Expect(os.Rename(path, path+".moved")).To(Succeed())
+
+			Expect(sink.Reopen()).To(Succeed())
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "after"})
+
+			Expect(readFile(path + ".moved")).To(ContainSubstring("before"))
+			Expect(readFile(path)).To(ContainSubstring("after"))
+			Expect(readFile(path)).NotTo(ContainSubstring("before"))
+		})
+
+		It("reports failing to open the file and opens it on a later write", func() {
+			Expect(os.Rename(path, path+".moved")).To(Succeed())
+			Expect(os.Mkdir(path, 0755)).To(Succeed())
+
+			Expect(sink.Reopen()).To(MatchError(ContainSubstring("is a directory")))
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "dropped"})
+			Expect(sink.Flush()).To(MatchError(ContainSubstring("is a directory")))
+
+			Expect(os.Remove(path)).To(Succeed())
+			sink.Log(lager.LogFormat{LogLevel: lager.INFO, Message: "after"})
+			Expect(sink.Flush()).To(Succeed())
+
+			Expect(readFile(path)).To(ContainSubstring("after"))
+			Expect(readFile(path)).NotTo(ContainSubstring("dropped"))
+		})
+
+		Context("when ReopenOnSIGHUP is set", func() {
+			BeforeEach(func() {
+				config.ReopenOnSIGHUP = true
+			})
+
+			It("reopens the file when the process receives SIGHUP", func() {
+				Expect(os.Rename(path, path+".moved")).To(Succeed())
+				process, err := os.FindProcess(os.Getpid())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(process.Signal(syscall.SIGHUP)).To(Succeed())
+
+				Eventually(func() error {
+					_, err := os.Stat(path)
+					return err
+				}).Should(Succeed())
+			})
+		})
+	})
+})
@@ -4,7 +4,6 @@ package lager
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 )
 
@@ -16,9 +15,13 @@ import (
 // Note the following log level conversions:
 //
 //	slog.LevelDebug -> lager.DEBUG
+//	slog.LevelInfo  -> lager.INFO
+//	slog.LevelWarn  -> lager.WARN
 //	slog.LevelError -> lager.ERROR
-//	slog.LevelError -> lager.FATAL
-//	default         -> lager.INFO
+//
+// Levels in between are rounded down, so slog.LevelWarn+2 becomes lager.WARN,
+// anything below slog.LevelInfo becomes lager.DEBUG and anything at or above
+// slog.LevelError becomes lager.ERROR.
 func NewHandler(l Logger) slog.Handler {
 	switch ll := l.(type) {
 	case *logger:
@@ -39,9 +42,9 @@ type handler struct {
 	decorators []decorator
 }
 
-// Enabled always returns true
-func (h *handler) Enabled(_ context.Context, _ slog.Level) bool {
-	return true
+// Enabled reports whether any of the logger's sinks might write the level
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Enabled(toLogLevel(level))
 }
 
 // Handle converts a slog.Record into a lager.LogFormat and passes it to every Sink
@@ -50,14 +53,12 @@ func (h *handler) Handle(_ context.Context, r slog.Record) error {
 		time:      r.Time,
 		Timestamp: formatTimestamp(r.Time),
 		Source:    h.logger.component,
-		Message:   fmt.Sprintf("%s.%s", h.logger.task, r.Message),
+		Message:   h.logger.task + "." + r.Message,
 		LogLevel:  toLogLevel(r.Level),
 		Data:      h.logger.baseData(h.decorate(attrFromRecord(r))),
 	}
 
-	for _, sink := range h.logger.sinks {
-		sink.Log(log)
-	}
+	h.logger.log(log)
 
 	return nil
 }
@@ -155,12 +156,14 @@ func processAttr(attr slog.Attr, target map[string]any) {
 
 // toLogLevel converts from slog levels to lager levels
 func toLogLevel(l slog.Level) LogLevel {
-	switch l {
-	case slog.LevelDebug:
+	switch {
+	case l < slog.LevelInfo:
 		return DEBUG
-	case slog.LevelError, slog.LevelWarn:
-		return ERROR
-	default:
+	case l < slog.LevelWarn:
 		return INFO
+	case l < slog.LevelError:
+		return WARN
+	default:
+		return ERROR
 	}
 }
@@ -5,6 +5,7 @@ package lager_test
 import (
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagertest"
+	"context"
 	"fmt"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -60,6 +61,35 @@ var _ = Describe("NewHandler", func() {
 		})))
 	})
 
+	It("logs a warn message", func() {
+		slog.New(h).Warn("foo", "bar", "baz")
+		logs := s.Logs()
+		Expect(logs).To(ConsistOf(MatchFields(IgnoreExtras, Fields{
+			"Source":  Equal("test"),
+			"Message": Equal("test.foo"),
+			"Data": SatisfyAll(
+				HaveLen(1),
+				HaveKeyWithValue("bar", "baz"),
+			),
+			"LogLevel": Equal(lager.WARN),
+		})))
+	})
+
+	It("rounds custom levels down to the nearest lager level", func() {
+		logger := slog.New(h)
+		logger.Log(context.Background(), slog.LevelDebug-4, "below-debug")
+		logger.Log(context.Background(), slog.LevelInfo+2, "above-info")
+		logger.Log(context.Background(), slog.LevelWarn+2, "above-warn")
+		logger.Log(context.Background(), slog.LevelError+4, "above-error")
+
+		logs := s.Logs()
+		Expect(logs).To(HaveLen(4))
+		Expect(logs[0].LogLevel).To(Equal(lager.DEBUG))
+		Expect(logs[1].LogLevel).To(Equal(lager.INFO))
+		Expect(logs[2].LogLevel).To(Equal(lager.WARN))
+		Expect(logs[3].LogLevel).To(Equal(lager.ERROR))
+	})
+
 	It("logs an error message", func() {
 		slog.New(h).Error("foo", "error", fmt.Errorf("boom"))
 		logs := s.Logs()
@@ -74,6 +104,18 @@ var _ = Describe("NewHandler", func() {
 		})))
 	})
 
+	It("reports levels that no sink would write as disabled", func() {
+		l = lager.NewLogger("test")
+		l.RegisterSink(lager.NewReconfigurableSink(s, lager.WARN))
+		h = lager.NewHandler(l)
+
+		Expect(h.Enabled(context.Background(), slog.LevelInfo)).To(BeFalse())
+		Expect(h.Enabled(context.Background(), slog.LevelWarn)).To(BeTrue())
+
+		slog.New(h).Info("foo")
+		Expect(s.Logs()).To(BeEmpty())
+	})
+
 	It("behaves like a slog.NewHandler", func() {
 		results := func() (result []map[string]any) {
 			for _, l := range s.Logs() {
@@ -0,0 +1,297 @@
+package lager
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The encoder below writes a LogFormat as JSON without going through
+// reflection for the common cases. Its output is identical to json.Marshal,
+// which it falls back to for any value type it does not know about.
+
+const maxPooledBufferSize = 64 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBufferSize {
+		return
+	}
+	*b = (*b)[:0]
+	bufferPool.Put(b)
+}
+
+// appendJSON appends the same JSON as ToJSON to dst.
+func (log LogFormat) appendJSON(dst []byte) []byte {
+	n := len(dst)
+	out, err := log.encodeJSON(dst)
+	if err == nil {
+		return out
+	}
+
+	log.Data = dataForJSONMarhallingError(err, log.Data)
+	out, err = log.encodeJSON(dst[:n])
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func (log LogFormat) encodeJSON(dst []byte) ([]byte, error) {
+	dst = append(dst, `{"timestamp":`...)
+	dst = appendJSONString(dst, log.Timestamp)
+	dst = append(dst, `,"source":`...)
+	dst = appendJSONString(dst, log.Source)
+	dst = append(dst, `,"message":`...)
+	dst = appendJSONString(dst, log.Message)
+	dst = append(dst, `,"log_level":`...)
+	dst = log.LogLevel.appendNumber(dst)
+	dst = append(dst, `,"data":`...)
+	dst, err := appendJSONData(dst, log.Data)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, '}'), nil
+}
+
+// appendPrettyJSON appends the same JSON as toPrettyJSON to dst.
+func (log LogFormat) appendPrettyJSON(dst []byte) []byte {
+	n := len(dst)
+	out, err := log.encodePrettyJSON(dst)
+	if err == nil {
+		return out
+	}
+
+	log.Data = dataForJSONMarhallingError(err, log.Data)
+	out, err = log.encodePrettyJSON(dst[:n])
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func (log LogFormat) encodePrettyJSON(dst []byte) ([]byte, error) {
+	t := log.time
+	if t.IsZero() {
+		t = parseTimestamp(log.Timestamp)
+	}
+
+	dst = append(dst, `{"timestamp":"`...)
+	dst = t.UTC().AppendFormat(dst, rfc3339Nano)
+	dst = append(dst, `","level":`...)
+	dst = appendJSONString(dst, log.LogLevel.String())
+	dst = append(dst, `,"source":`...)
+	dst = appendJSONString(dst, log.Source)
+	dst = append(dst, `,"message":`...)
+	dst = appendJSONString(dst, log.Message)
+	dst = append(dst, `,"data":`...)
+	dst, err := appendJSONData(dst, log.Data)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, '}'), nil
+}
+
+func appendJSONData(dst []byte, data map[string]interface{}) ([]byte, error) {
+	if data == nil {
+		return append(dst, "null"...), nil
+	}
+
+	// Keys are sorted like json.Marshal does, on the stack for small maps
+	var stack [16]string
+	keys := stack[:0]
+	if len(data) > len(stack) {
+		keys = make([]string, 0, len(data))
+	}
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+
+	dst = append(dst, '{')
+	for i, k := range keys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendJSONString(dst, k)
+		dst = append(dst, ':')
+
+		var err error
+		dst, err = appendJSONValue(dst, data[k])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+func appendJSONValue(dst []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(dst, "null"...), nil
+	case string:
+		return appendJSONString(dst, v), nil
+	case bool:
+		return strconv.AppendBool(dst, v), nil
+	case int:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(dst, v, 10), nil
+	case uint:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(dst, v, 10), nil
+	case float32:
+		if !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0) {
+			return appendJSONFloat(dst, float64(v), 32), nil
+		}
+	case float64:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			return appendJSONFloat(dst, v, 64), nil
+		}
+	case time.Duration:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case Data:
+		return appendJSONData(dst, v)
+	case map[string]interface{}:
+		return appendJSONData(dst, v)
+	case []interface{}:
+		if v == nil {
+			return append(dst, "null"...), nil
+		}
+		dst = append(dst, '[')
+		for i, e := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			var err error
+			dst, err = appendJSONValue(dst, e)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, ']'), nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, b...), nil
+}
+
+// appendJSONFloat formats floats the same way as encoding/json.
+func appendJSONFloat(dst []byte, f float64, bits int) []byte {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst
+}
+
+const hex = "0123456789abcdef"
+
+// appendJSONString quotes s the same way as encoding/json, including escaping
+// of HTML characters.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// sortKeys sorts small slices with an insertion sort to avoid the allocation
+// of sort.Strings.
+func sortKeys(keys []string) {
+	if len(keys) > 16 {
+		sort.Strings(keys)
+		return
+	}
+	for i := 1; i < len(keys); i++ {
+		for j := i; j > 0 && keys[j] < keys[j-1]; j-- {
+			keys[j], keys[j-1] = keys[j-1], keys[j]
+		}
+	}
+}
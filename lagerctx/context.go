@@ -45,10 +45,13 @@ type discardLogger struct{}
 
 func (*discardLogger) Debug(string, ...lager.Data)                  {}
 func (*discardLogger) Info(string, ...lager.Data)                   {}
+func (*discardLogger) Warn(string, ...lager.Data)                   {}
 func (*discardLogger) Error(string, error, ...lager.Data)           {}
 func (*discardLogger) Fatal(string, error, ...lager.Data)           {}
 func (*discardLogger) RegisterSink(lager.Sink)                      {}
 func (*discardLogger) SessionName() string                          { return "" }
+func (*discardLogger) Enabled(lager.LogLevel) bool                  { return false }
+func (*discardLogger) Flush() error                                 { return nil }
 func (d *discardLogger) Session(string, ...lager.Data) lager.Logger { return d }
 func (d *discardLogger) WithData(lager.Data) lager.Logger           { return d }
 func (d *discardLogger) WithTraceInfo(*http.Request) lager.Logger   { return d }
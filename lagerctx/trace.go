@@ -0,0 +1,74 @@
+package lagerctx
+
+import (
+	"context"
+	"net/http"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// NewContextWithTraceInfo returns a derived context containing the trace.
+func NewContextWithTraceInfo(parent context.Context, trace lager.TraceInfo) context.Context {
+	return context.WithValue(parent, traceKey{}, trace)
+}
+
+// TraceInfoFromContext returns the trace contained in the context, if there
+// is one.
+func TraceInfoFromContext(ctx context.Context) (lager.TraceInfo, bool) {
+	trace, ok := ctx.Value(traceKey{}).(lager.TraceInfo)
+	return trace, ok
+}
+
+// FromContextWithTraceInfo returns the logger contained in the context with
+// the context's trace, if there is one, added to its data.
+func FromContextWithTraceInfo(ctx context.Context) lager.Logger {
+	logger := FromContext(ctx)
+
+	trace, ok := TraceInfoFromContext(ctx)
+	if !ok {
+		return logger
+	}
+
+	return logger.WithData(trace.Data())
+}
+
+// NewTraceRoundTripper returns an http.RoundTripper that passes the trace in
+// each request's context on to the server in the X-Vcap-Request-Id and
+// traceparent headers, unless the request already has them. It uses
+// http.DefaultTransport when next is nil.
+func NewTraceRoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &traceRoundTripper{next: next}
+}
+
+type traceRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (t *traceRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace, ok := TraceInfoFromContext(req.Context())
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the request they are given
+	req = req.Clone(req.Context())
+
+	if req.Header.Get(lager.RequestIdHeader) == "" {
+		req.Header.Set(lager.RequestIdHeader, trace.RequestID())
+	}
+
+	if req.Header.Get(lager.TraceparentHeader) == "" {
+		req.Header.Set(lager.TraceparentHeader, trace.Traceparent())
+		if trace.TraceState != "" {
+			req.Header.Set(lager.TracestateHeader, trace.TraceState)
+		}
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// traceKey is used to retrieve the trace from the context.
+type traceKey struct{}
@@ -0,0 +1,155 @@
+package lagerctx_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagerctx"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+)
+
+var _ = Describe("Trace Context", func() {
+	var trace lager.TraceInfo
+
+	BeforeEach(func() {
+		sampled := true
+		trace = lager.TraceInfo{
+			TraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:       "53995c3f42cd8ad8",
+			ParentSpanID: "00f067aa0ba902b7",
+			Sampled:      &sampled,
+			TraceState:   "rojo=00f067aa0ba902b7",
+		}
+	})
+
+	It("can store traces inside contexts", func() {
+		ctx := lagerctx.NewContextWithTraceInfo(context.Background(), trace)
+
+		stored, ok := lagerctx.TraceInfoFromContext(ctx)
+		Expect(ok).To(BeTrue())
+		Expect(stored).To(Equal(trace))
+	})
+
+	It("reports when there is no trace in the context", func() {
+		_, ok := lagerctx.TraceInfoFromContext(context.Background())
+		Expect(ok).To(BeFalse())
+	})
+
+	It("can add the trace to the logger in the context", func() {
+		l := lagertest.NewTestLogger("lagerctx")
+		ctx := lagerctx.NewContext(context.Background(), l)
+		ctx = lagerctx.NewContextWithTraceInfo(ctx, trace)
+
+		lagerctx.FromContextWithTraceInfo(ctx).Info("from-a-context")
+
+		Expect(l.Logs()).To(HaveLen(1))
+		Expect(l.Logs()[0].Data).To(Equal(lager.Data{
+			"trace-id":       "4bf92f3577b34da6a3ce929d0e0e4736",
+			"span-id":        "53995c3f42cd8ad8",
+			"parent-span-id": "00f067aa0ba902b7",
+			"sampled":        true,
+			"trace-state":    "rojo=00f067aa0ba902b7",
+		}))
+	})
+
+	It("returns the plain logger when there is no trace in the context", func() {
+		l := lagertest.NewTestLogger("lagerctx")
+		ctx := lagerctx.NewContext(context.Background(), l)
+
+		lagerctx.FromContextWithTraceInfo(ctx).Info("from-a-context")
+
+		Expect(l.Logs()[0].Data).To(BeEmpty())
+	})
+
+	Describe("NewTraceRoundTripper", func() {
+		var (
+			server   *httptest.Server
+			received chan http.Header
+			client   *http.Client
+		)
+
+		BeforeEach(func() {
+			received = make(chan http.Header, 1)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received <- r.Header
+			}))
+			client = &http.Client{Transport: lagerctx.NewTraceRoundTripper(nil)}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		get := func(ctx context.Context, header http.Header) *http.Request {
+			req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+			Expect(err).NotTo(HaveOccurred())
+			for k, v := range header {
+				req.Header[k] = v
+			}
+
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			return req
+		}
+
+		It("adds the trace headers from the context", func() {
+			get(lagerctx.NewContextWithTraceInfo(context.Background(), trace), nil)
+
+			var header http.Header
+			Eventually(received).Should(Receive(&header))
+			Expect(header.Get("X-Vcap-Request-Id")).To(Equal("4bf92f35-77b3-4da6-a3ce-929d0e0e4736"))
+			Expect(header.Get("traceparent")).To(Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-53995c3f42cd8ad8-01"))
+			Expect(header.Get("tracestate")).To(Equal("rojo=00f067aa0ba902b7"))
+		})
+
+		It("lets the server continue the trace with WithTraceInfo", func() {
+			get(lagerctx.NewContextWithTraceInfo(context.Background(), trace), nil)
+
+			var header http.Header
+			Eventually(received).Should(Receive(&header))
+
+			continued, ok := lager.NewTraceInfo(&http.Request{Header: header})
+			Expect(ok).To(BeTrue())
+			Expect(continued.TraceID).To(Equal(trace.TraceID))
+			Expect(continued.ParentSpanID).To(Equal(trace.SpanID))
+		})
+
+		It("pads 64-bit trace IDs", func() {
+			trace.TraceID = "a3ce929d0e0e4736"
+			trace.Sampled = nil
+			get(lagerctx.NewContextWithTraceInfo(context.Background(), trace), nil)
+
+			var header http.Header
+			Eventually(received).Should(Receive(&header))
+			Expect(header.Get("X-Vcap-Request-Id")).To(Equal("00000000-0000-0000-a3ce-929d0e0e4736"))
+			Expect(header.Get("traceparent")).To(Equal("00-0000000000000000a3ce929d0e0e4736-53995c3f42cd8ad8-00"))
+		})
+
+		It("does not replace headers the request already has", func() {
+			req := get(lagerctx.NewContextWithTraceInfo(context.Background(), trace), http.Header{
+				"X-Vcap-Request-Id": {"7f461654-74d1-1ee5-8367-77d85df2cdab"},
+			})
+
+			var header http.Header
+			Eventually(received).Should(Receive(&header))
+			Expect(header.Get("X-Vcap-Request-Id")).To(Equal("7f461654-74d1-1ee5-8367-77d85df2cdab"))
+			Expect(header.Get("traceparent")).NotTo(BeEmpty())
+			Expect(req.Header.Get("traceparent")).To(BeEmpty())
+		})
+
+		It("passes requests without a trace through unchanged", func() {
+			get(context.Background(), nil)
+
+			var header http.Header
+			Eventually(received).Should(Receive(&header))
+			Expect(header).NotTo(HaveKey("X-Vcap-Request-Id"))
+			Expect(header).NotTo(HaveKey("Traceparent"))
+		})
+	})
+})
@@ -0,0 +1,321 @@
+package lagerflags
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// ConfigWatcher applies changes to a JSON LagerConfig file to a running
+// logger.
+//
+// Changes to log_level are applied to the ReconfigurableSink. Changes to
+// redaction, max_data_string_length, time_format and outputs replace the
+// sinks below it; outputs are only reopened when time_format or outputs
+// change. Every change is logged by the watcher.
+type ConfigWatcher struct {
+	path   string
+	logger lager.Logger
+	sink   *lager.ReconfigurableSink
+	swap   *swapSink
+
+	lock     sync.Mutex
+	config   LagerConfig
+	contents []byte
+	outputs  lager.Sink
+	failing  bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// WatchConfigFile returns a logger configured by the JSON LagerConfig in the
+// file at path, where fields that are left out keep the values from
+// DefaultLagerConfig. Unless interval is zero, the file is checked for
+// changes every interval until Close is called.
+func WatchConfigFile(component, path string, interval time.Duration) (lager.Logger, *ConfigWatcher, error) {
+	contents, config, err := readConfigFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outputs, err := outputSink(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filtered, err := filterSink(config, outputs)
+	if err != nil {
+		lager.CloseSink(outputs) //nolint:errcheck
+		return nil, nil, err
+	}
+
+	swap := newSwapSink(filtered)
+	logger, sink, err := newLogger(component, config.LogLevel, swap)
+	if err != nil {
+		lager.CloseSink(outputs) //nolint:errcheck
+		return nil, nil, err
+	}
+
+	w := &ConfigWatcher{
+		path:     path,
+		logger:   logger.Session("config-watcher", lager.Data{"path": path}),
+		sink:     sink,
+		swap:     swap,
+		config:   config,
+		contents: contents,
+		outputs:  outputs,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if interval > 0 {
+		go w.watch(interval)
+	} else {
+		close(w.done)
+	}
+
+	return logger, w, nil
+}
+
+// Sink returns the sink that filters logs by level, e.g. for
+// NewLogLevelHandler.
+func (w *ConfigWatcher) Sink() *lager.ReconfigurableSink {
+	return w.sink
+}
+
+// Config returns the config that is currently applied.
+func (w *ConfigWatcher) Config() LagerConfig {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.config
+}
+
+// Reload reads the file and applies it. An invalid config is logged and
+// returned, and the logger keeps its current config.
+func (w *ConfigWatcher) Reload() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	contents, err := os.ReadFile(w.path)
+	if err != nil {
+		w.logger.Error("failed-to-reload", err)
+		return err
+	}
+
+	return w.load(contents)
+}
+
+// Close stops watching the file and closes the outputs.
+func (w *ConfigWatcher) Close() error {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return lager.CloseSink(w.outputs)
+}
+
+func (w *ConfigWatcher) watch(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.check()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// check reloads the file if its contents have changed. Errors are logged once
+// rather than on every check.
+func (w *ConfigWatcher) check() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	contents, err := os.ReadFile(w.path)
+	if err != nil {
+		if !w.failing {
+			w.logger.Error("failed-to-reload", err)
+			w.failing = true
+		}
+		return
+	}
+	w.failing = false
+
+	if bytes.Equal(contents, w.contents) {
+		return
+	}
+
+	w.load(contents) //nolint:errcheck
+}
+
+// load swaps in the config in contents once it has been validated and its
+// sinks built. The caller must hold the lock.
+func (w *ConfigWatcher) load(contents []byte) error {
+	w.contents = contents
+
+	config, err := parseConfig(contents)
+	if err == nil {
+		err = config.Validate()
+	}
+	if err != nil {
+		w.logger.Error("failed-to-reload", err)
+		return err
+	}
+
+	changes := configChanges(w.config, config)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	outputs := w.outputs
+	reopen := config.TimeFormat != w.config.TimeFormat || !reflect.DeepEqual(config.Outputs, w.config.Outputs)
+	if reopen {
+		outputs, err = outputSink(config)
+		if err != nil {
+			w.logger.Error("failed-to-reload", err)
+			return err
+		}
+	}
+
+	filtered, err := filterSink(config, outputs)
+	if err != nil {
+		if reopen {
+			lager.CloseSink(outputs) //nolint:errcheck
+		}
+		w.logger.Error("failed-to-reload", err)
+		return err
+	}
+
+	levelChanged := config.LogLevel != w.config.LogLevel
+
+	// Once set returns no logs are being written to the old outputs, so they
+	// can be closed without losing any
+	w.swap.set(filtered)
+	if reopen {
+		lager.CloseSink(w.outputs) //nolint:errcheck
+		w.outputs = outputs
+	}
+	w.config = config
+
+	current := w.sink.GetMinLevel()
+	if !levelChanged {
+		logAtLeast(w.logger, current, "config-reloaded", changes)
+		return nil
+	}
+
+	// Log the change while the level still lets it through: before the level
+	// is raised, and after it is lowered
+	level, _ := lager.LogLevelFromString(config.LogLevel)
+	if level > current {
+		logAtLeast(w.logger, current, "config-reloaded", changes)
+		w.sink.SetMinLevel(level)
+	} else {
+		w.sink.SetMinLevel(level)
+		logAtLeast(w.logger, level, "config-reloaded", changes)
+	}
+	return nil
+}
+
+func readConfigFile(path string) ([]byte, LagerConfig, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, LagerConfig{}, err
+	}
+
+	config, err := parseConfig(contents)
+	if err != nil {
+		return nil, LagerConfig{}, err
+	}
+
+	return contents, config, config.Validate()
+}
+
+func parseConfig(contents []byte) (LagerConfig, error) {
+	config := DefaultLagerConfig()
+	if err := json.Unmarshal(contents, &config); err != nil {
+		return LagerConfig{}, fmt.Errorf("invalid config: %w", err)
+	}
+	return config, nil
+}
+
+// configChanges describes each field that differs between the configs.
+func configChanges(from, to LagerConfig) lager.Data {
+	changes := lager.Data{}
+	change := func(field string, from, to interface{}) {
+		if !reflect.DeepEqual(from, to) {
+			changes[field] = lager.Data{"from": from, "to": to}
+		}
+	}
+
+	change("log_level", from.LogLevel, to.LogLevel)
+	change("redact_secrets", from.RedactSecrets, to.RedactSecrets)
+	change("redact_patterns", strings.Join(from.RedactPatterns, ","), strings.Join(to.RedactPatterns, ","))
+	change("time_format", from.TimeFormat.String(), to.TimeFormat.String())
+	change("max_data_string_length", from.MaxDataStringLength, to.MaxDataStringLength)
+	change("outputs", outputsString(from.Outputs), outputsString(to.Outputs))
+
+	return changes
+}
+
+func outputsString(outputs []LagerOutput) string {
+	o := LagerOutputs(outputs)
+	return o.String()
+}
+
+// swapSink passes logs on to a sink that can be replaced while it is in use.
+// Replacing the sink waits for the logs being written to the old one.
+type swapSink struct {
+	lock sync.RWMutex
+	sink lager.Sink
+}
+
+func newSwapSink(sink lager.Sink) *swapSink {
+	return &swapSink{sink: sink}
+}
+
+func (s *swapSink) set(sink lager.Sink) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sink = sink
+}
+
+func (s *swapSink) Log(log lager.LogFormat) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	s.sink.Log(log)
+}
+
+func (s *swapSink) Enabled(level lager.LogLevel) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return lager.SinkEnabled(s.sink, level)
+}
+
+func (s *swapSink) Flush() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return lager.FlushSink(s.sink)
+}
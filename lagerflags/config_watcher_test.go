@@ -0,0 +1,230 @@
+package lagerflags_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagerflags"
+)
+
+var _ = Describe("ConfigWatcher", func() {
+	var (
+		configPath string
+		logPath    string
+		interval   time.Duration
+		logger     lager.Logger
+		watcher    *lagerflags.ConfigWatcher
+	)
+
+	writeConfig := func(config string) {
+		Expect(os.WriteFile(configPath, []byte(config), 0644)).To(Succeed())
+	}
+
+	logs := func() []map[string]interface{} {
+		contents, err := os.ReadFile(logPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		var entries []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry map[string]interface{}
+			Expect(json.Unmarshal([]byte(line), &entry)).To(Succeed())
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	lastLog := func() map[string]interface{} {
+		entries := logs()
+		Expect(entries).NotTo(BeEmpty())
+		return entries[len(entries)-1]
+	}
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		configPath = filepath.Join(dir, "lager.json")
+		logPath = filepath.Join(dir, "app.log")
+		interval = 0
+
+		writeConfig(`{"log_level": "info", "outputs": [{"type": "file", "path": "` + logPath + `"}]}`)
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		logger, watcher, err = lagerflags.WatchConfigFile("test", configPath, interval)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(watcher.Close()).To(Succeed())
+	})
+
+	It("configures the logger from the file, with defaults for missing fields", func() {
+		Expect(watcher.Config().TimeFormat).To(Equal(lagerflags.FormatUnixEpoch))
+		Expect(watcher.Sink().GetMinLevel()).To(Equal(lager.INFO))
+
+		logger.Debug("hidden")
+		logger.Info("hello")
+		Expect(logs()).To(HaveLen(1))
+		Expect(lastLog()).To(HaveKeyWithValue("message", "test.hello"))
+	})
+
+	It("applies a new log level and logs what changed", func() {
+		writeConfig(`{"log_level": "debug", "outputs": [{"type": "file", "path": "` + logPath + `"}]}`)
+		Expect(watcher.Reload()).To(Succeed())
+
+		Expect(watcher.Sink().GetMinLevel()).To(Equal(lager.DEBUG))
+		entry := lastLog()
+		Expect(entry).To(HaveKeyWithValue("message", "test.config-watcher.config-reloaded"))
+		Expect(entry["data"]).To(HaveKeyWithValue("log_level", map[string]interface{}{"from": "info", "to": "debug"}))
+		Expect(entry["data"]).NotTo(HaveKey("time_format"))
+
+		logger.Debug("shown")
+		Expect(lastLog()).To(HaveKeyWithValue("message", "test.shown"))
+	})
+
+	It("logs a change that raises the level above info", func() {
+		writeConfig(`{"log_level": "error", "outputs": [{"type": "file", "path": "` + logPath + `"}]}`)
+		Expect(watcher.Reload()).To(Succeed())
+
+		Expect(watcher.Sink().GetMinLevel()).To(Equal(lager.ERROR))
+		entry := lastLog()
+		Expect(entry).To(HaveKeyWithValue("message", "test.config-watcher.config-reloaded"))
+		Expect(entry["data"]).To(HaveKeyWithValue("log_level", map[string]interface{}{"from": "info", "to": "error"}))
+
+		logger.Info("hidden")
+		Expect(logs()).To(HaveLen(1))
+	})
+
+	Context("when the level is already above info", func() {
+		BeforeEach(func() {
+			writeConfig(`{"log_level": "error", "outputs": [{"type": "file", "path": "` + logPath + `"}]}`)
+		})
+
+		It("logs a change that raises the level further", func() {
+			writeConfig(`{"log_level": "fatal", "outputs": [{"type": "file", "path": "` + logPath + `"}]}`)
+			Expect(watcher.Reload()).To(Succeed())
+
+			Expect(watcher.Sink().GetMinLevel()).To(Equal(lager.FATAL))
+			entry := lastLog()
+			Expect(entry).To(HaveKeyWithValue("message", "test.config-watcher.config-reloaded"))
+			Expect(entry).To(HaveKeyWithValue("log_level", 2.0))
+			Expect(entry["data"]).To(HaveKeyWithValue("log_level", map[string]interface{}{"from": "error", "to": "fatal"}))
+		})
+
+		It("logs a change that leaves the level alone", func() {
+			writeConfig(`{"log_level": "error", "max_data_string_length": 20, "outputs": [{"type": "file", "path": "` + logPath + `"}]}`)
+			Expect(watcher.Reload()).To(Succeed())
+
+			Expect(lastLog()).To(HaveKeyWithValue("message", "test.config-watcher.config-reloaded"))
+		})
+	})
+
+	It("does not lose logs written while the outputs are replaced", func() {
+		otherPath := filepath.Join(filepath.Dir(logPath), "other.log")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 250; j++ {
+					logger.Info("hello")
+				}
+			}()
+		}
+
+		writeConfig(`{"outputs": [{"type": "file", "path": "` + otherPath + `"}]}`)
+		Expect(watcher.Reload()).To(Succeed())
+		wg.Wait()
+
+		contents, err := os.ReadFile(logPath)
+		Expect(err).NotTo(HaveOccurred())
+		otherContents, err := os.ReadFile(otherPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		all := string(contents) + string(otherContents)
+		Expect(strings.Count(all, `"message":"test.hello"`)).To(Equal(1000))
+	})
+
+	It("applies new redaction and truncation settings", func() {
+		writeConfig(`{
+			"redact_secrets": true,
+			"redact_patterns": ["s3cr3t"],
+			"max_data_string_length": 20,
+			"outputs": [{"type": "file", "path": "` + logPath + `"}]
+		}`)
+		Expect(watcher.Reload()).To(Succeed())
+
+		logger.Info("hello", lager.Data{"token": "s3cr3t", "long": strings.Repeat("a", 25)})
+		Expect(lastLog()["data"]).To(Equal(map[string]interface{}{
+			"token": "*REDACTED*",
+			"long":  "aaaaaaaa-(truncated)",
+		}))
+	})
+
+	It("reopens the outputs with a new time format", func() {
+		writeConfig(`{"time_format": "rfc3339", "outputs": [{"type": "file", "path": "` + logPath + `"}]}`)
+		Expect(watcher.Reload()).To(Succeed())
+
+		logger.Info("hello")
+		Expect(lastLog()["timestamp"]).To(MatchRegexp(`^\d{4}-\d{2}-\d{2}T`))
+	})
+
+	It("keeps the current config when the new one is invalid", func() {
+		writeConfig(`{"log_level": "loud", "max_data_string_length": -1}`)
+		err := watcher.Reload()
+		Expect(err).To(MatchError(ContainSubstring(`invalid log_level "loud"`)))
+		Expect(err).To(MatchError(ContainSubstring("invalid max_data_string_length -1")))
+
+		Expect(watcher.Sink().GetMinLevel()).To(Equal(lager.INFO))
+		Expect(lastLog()).To(HaveKeyWithValue("message", "test.config-watcher.failed-to-reload"))
+	})
+
+	It("does not log when nothing has changed", func() {
+		writeConfig(`{"log_level": "info", "time_format": "unix-epoch", "outputs": [{"type": "file", "path": "` + logPath + `"}]}`)
+		Expect(watcher.Reload()).To(Succeed())
+
+		_, err := os.Stat(logPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(logs()).To(BeEmpty())
+	})
+
+	Context("when watching the file", func() {
+		BeforeEach(func() {
+			interval = 10 * time.Millisecond
+		})
+
+		It("applies changes to the file", func() {
+			writeConfig(`{"log_level": "error", "outputs": [{"type": "file", "path": "` + logPath + `"}]}`)
+
+			Eventually(watcher.Sink().GetMinLevel).Should(Equal(lager.ERROR))
+			Expect(watcher.Config().LogLevel).To(Equal(lagerflags.ERROR))
+		})
+
+		It("logs an invalid file once", func() {
+			writeConfig(`{"log_level": `)
+
+			Eventually(logs).Should(HaveLen(1))
+			Consistently(logs, 100*time.Millisecond).Should(HaveLen(1))
+			Expect(lastLog()).To(HaveKeyWithValue("message", "test.config-watcher.failed-to-reload"))
+		})
+	})
+
+	It("fails when the file is invalid to start with", func() {
+		writeConfig(`{"log_level": "loud"}`)
+
+		_, _, err := lagerflags.WatchConfigFile("test", configPath, 0)
+		Expect(err).To(MatchError(ContainSubstring(`invalid log_level "loud"`)))
+	})
+})
@@ -0,0 +1,116 @@
+package lagerflags
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables read by
+// ApplyEnv, e.g. LAGER_LOG_LEVEL.
+const DefaultEnvPrefix = "LAGER_"
+
+// The environment variables read by ApplyEnv, after the prefix.
+const (
+	EnvLogLevel            = "LOG_LEVEL"
+	EnvRedactSecrets       = "REDACT_SECRETS"
+	EnvRedactPatterns      = "REDACT_PATTERNS"
+	EnvTimeFormat          = "TIME_FORMAT"
+	EnvMaxDataStringLength = "MAX_DATA_STRING_LENGTH"
+	EnvOutputs             = "OUTPUTS"
+)
+
+// ApplyEnv returns config with each field whose environment variable is set
+// replaced by the variable's value. Redact patterns are separated by
+// semicolons, since commas appear in repetitions like \d{3,4}; a pattern
+// containing a semicolon has to go in a JSON config. Outputs are separated by
+// commas and take the format of the logOutput flag. Every malformed variable
+// is reported, joined into a single error.
+//
+// Settings are meant to be layered from the defaults, through a JSON config
+// and the environment, to the flags given on the command line:
+//
+//	config := DefaultLagerConfig()
+//	json.Unmarshal(contents, &config)
+//	config, err := ApplyEnv(config, DefaultEnvPrefix)
+//	config = ApplyFlags(config, flag.CommandLine)
+func ApplyEnv(config LagerConfig, prefix string) (LagerConfig, error) {
+	var errs []error
+	lookup := func(name string) (string, string, bool) {
+		name = prefix + name
+		value, ok := os.LookupEnv(name)
+		return name, value, ok
+	}
+
+	if name, value, ok := lookup(EnvLogLevel); ok {
+		if _, err := lager.LogLevelFromString(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: must be one of debug, info, warn, error or fatal", name, value))
+		} else {
+			config.LogLevel = value
+		}
+	}
+
+	if name, value, ok := lookup(EnvRedactSecrets); ok {
+		redact, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: must be true or false", name, value))
+		} else {
+			config.RedactSecrets = redact
+		}
+	}
+
+	if name, value, ok := lookup(EnvRedactPatterns); ok {
+		var patterns []string
+		valid := true
+		if value != "" {
+			patterns = strings.Split(value, ";")
+			for i, pattern := range patterns {
+				if _, err := regexp.Compile(pattern); err != nil {
+					errs = append(errs, fmt.Errorf("invalid %s[%d]: %w", name, i, err))
+					valid = false
+				}
+			}
+		}
+		if valid {
+			config.RedactPatterns = patterns
+		}
+	}
+
+	if name, value, ok := lookup(EnvTimeFormat); ok {
+		if err := config.TimeFormat.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: must be unix-epoch or rfc3339", name, value))
+		}
+	}
+
+	if name, value, ok := lookup(EnvMaxDataStringLength); ok {
+		length, err := strconv.Atoi(value)
+		if err != nil || length < 0 {
+			errs = append(errs, fmt.Errorf("invalid %s %q: must be a length that is not negative", name, value))
+		} else {
+			config.MaxDataStringLength = length
+		}
+	}
+
+	if name, value, ok := lookup(EnvOutputs); ok {
+		var outputs LagerOutputs
+		valid := true
+		if value != "" {
+			for _, output := range strings.Split(value, ",") {
+				if err := outputs.Set(output); err != nil {
+					errs = append(errs, fmt.Errorf("invalid %s: %w", name, err))
+					valid = false
+				}
+			}
+		}
+		if valid {
+			config.Outputs = outputs
+		}
+	}
+
+	return config, errors.Join(errs...)
+}
@@ -0,0 +1,144 @@
+package lagerflags_test
+
+import (
+	"flag"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/lager/v3/lagerflags"
+)
+
+var _ = Describe("Environment", func() {
+	Describe("ApplyEnv", func() {
+		It("leaves the config alone when no variables are set", func() {
+			config := lagerflags.DefaultLagerConfig()
+			config.RedactPatterns = []string{"secret"}
+
+			applied, err := lagerflags.ApplyEnv(config, "TEST_LAGER_")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(applied).To(Equal(config))
+		})
+
+		It("replaces every field whose variable is set", func() {
+			GinkgoT().Setenv("TEST_LAGER_LOG_LEVEL", "debug")
+			GinkgoT().Setenv("TEST_LAGER_REDACT_SECRETS", "true")
+			GinkgoT().Setenv("TEST_LAGER_REDACT_PATTERNS", `s3cr3t;\d{3,4}`)
+			GinkgoT().Setenv("TEST_LAGER_TIME_FORMAT", "rfc3339")
+			GinkgoT().Setenv("TEST_LAGER_MAX_DATA_STRING_LENGTH", "50")
+			GinkgoT().Setenv("TEST_LAGER_OUTPUTS", "stderr?format=console,file:/tmp/app.log")
+
+			config, err := lagerflags.ApplyEnv(lagerflags.DefaultLagerConfig(), "TEST_LAGER_")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(lagerflags.LagerConfig{
+				LogLevel:            lagerflags.DEBUG,
+				RedactSecrets:       true,
+				RedactPatterns:      []string{"s3cr3t", `\d{3,4}`},
+				TimeFormat:          lagerflags.FormatRFC3339,
+				MaxDataStringLength: 50,
+				Outputs: []lagerflags.LagerOutput{
+					{Type: lagerflags.OutputStderr, Format: lagerflags.FormatConsole},
+					{Type: lagerflags.OutputFile, Path: "/tmp/app.log"},
+				},
+			}))
+		})
+
+		It("clears lists with empty variables", func() {
+			GinkgoT().Setenv("TEST_LAGER_REDACT_PATTERNS", "")
+			GinkgoT().Setenv("TEST_LAGER_OUTPUTS", "")
+
+			config := lagerflags.DefaultLagerConfig()
+			config.RedactPatterns = []string{"secret"}
+			config.Outputs = []lagerflags.LagerOutput{{Type: lagerflags.OutputStderr}}
+
+			config, err := lagerflags.ApplyEnv(config, "TEST_LAGER_")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.RedactPatterns).To(BeEmpty())
+			Expect(config.Outputs).To(BeEmpty())
+		})
+
+		It("reports every malformed variable and keeps the config's values", func() {
+			GinkgoT().Setenv("TEST_LAGER_LOG_LEVEL", "loud")
+			GinkgoT().Setenv("TEST_LAGER_REDACT_SECRETS", "sometimes")
+			GinkgoT().Setenv("TEST_LAGER_TIME_FORMAT", "iso8601")
+			GinkgoT().Setenv("TEST_LAGER_REDACT_PATTERNS", "s3cr3t;t[o0ken")
+			GinkgoT().Setenv("TEST_LAGER_MAX_DATA_STRING_LENGTH", "-1")
+			GinkgoT().Setenv("TEST_LAGER_OUTPUTS", "stdout,printer")
+
+			config, err := lagerflags.ApplyEnv(lagerflags.DefaultLagerConfig(), "TEST_LAGER_")
+			Expect(err).To(MatchError(ContainSubstring(`invalid TEST_LAGER_LOG_LEVEL "loud"`)))
+			Expect(err).To(MatchError(ContainSubstring(`invalid TEST_LAGER_REDACT_SECRETS "sometimes"`)))
+			Expect(err).To(MatchError(ContainSubstring(`invalid TEST_LAGER_TIME_FORMAT "iso8601"`)))
+			Expect(err).To(MatchError(ContainSubstring("invalid TEST_LAGER_REDACT_PATTERNS[1]: error parsing regexp")))
+			Expect(err).To(MatchError(ContainSubstring(`invalid TEST_LAGER_MAX_DATA_STRING_LENGTH "-1"`)))
+			Expect(err).To(MatchError(ContainSubstring(`invalid TEST_LAGER_OUTPUTS: invalid log output "printer"`)))
+			Expect(config).To(Equal(lagerflags.DefaultLagerConfig()))
+		})
+	})
+
+	Describe("ApplyFlags", func() {
+		var flagSet *flag.FlagSet
+
+		BeforeEach(func() {
+			flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+			flagSet.SetOutput(io.Discard)
+			lagerflags.AddFlags(flagSet)
+		})
+
+		It("only replaces the fields whose flags were given", func() {
+			Expect(flagSet.Parse([]string{"-logLevel", "error", "-logOutput", "stderr"})).To(Succeed())
+
+			config := lagerflags.DefaultLagerConfig()
+			config.RedactSecrets = true
+			config.TimeFormat = lagerflags.FormatRFC3339
+			config.MaxDataStringLength = 50
+
+			Expect(lagerflags.ApplyFlags(config, flagSet)).To(Equal(lagerflags.LagerConfig{
+				LogLevel:            lagerflags.ERROR,
+				RedactSecrets:       true,
+				TimeFormat:          lagerflags.FormatRFC3339,
+				MaxDataStringLength: 50,
+				Outputs:             []lagerflags.LagerOutput{{Type: lagerflags.OutputStderr}},
+			}))
+		})
+
+		It("reads the values from the given flag set", func() {
+			var flags lagerflags.Flags
+			other := flag.NewFlagSet("other", flag.ContinueOnError)
+			other.SetOutput(io.Discard)
+			flags.AddFlags(other)
+			Expect(other.Parse([]string{
+				"-logLevel", "debug",
+				"-redactSecrets",
+				"-redactPatterns", "s3cr3t",
+				"-timeFormat", "rfc3339",
+				"-maxDataStringLength", "50",
+				"-logOutput", "stderr",
+			})).To(Succeed())
+			Expect(flagSet.Parse([]string{"-logLevel", "error"})).To(Succeed())
+
+			Expect(lagerflags.ApplyFlags(lagerflags.DefaultLagerConfig(), other)).To(Equal(lagerflags.LagerConfig{
+				LogLevel:            lagerflags.DEBUG,
+				RedactSecrets:       true,
+				RedactPatterns:      []string{"s3cr3t"},
+				TimeFormat:          lagerflags.FormatRFC3339,
+				MaxDataStringLength: 50,
+				Outputs:             []lagerflags.LagerOutput{{Type: lagerflags.OutputStderr}},
+			}))
+		})
+
+		It("takes precedence over the environment", func() {
+			GinkgoT().Setenv("TEST_LAGER_LOG_LEVEL", "debug")
+			GinkgoT().Setenv("TEST_LAGER_REDACT_SECRETS", "true")
+			Expect(flagSet.Parse([]string{"-logLevel", "warn"})).To(Succeed())
+
+			config, err := lagerflags.ApplyEnv(lagerflags.DefaultLagerConfig(), "TEST_LAGER_")
+			Expect(err).NotTo(HaveOccurred())
+			config = lagerflags.ApplyFlags(config, flagSet)
+
+			Expect(config.LogLevel).To(Equal(lagerflags.WARN))
+			Expect(config.RedactSecrets).To(BeTrue())
+		})
+	})
+})
@@ -51,6 +51,7 @@ var _ = Describe("CF-Lager", func() {
 
 		Expect(session.Out.Contents()).NotTo(ContainSubstring("debug"))
 		Expect(session.Out.Contents()).To(ContainSubstring("info"))
+		Expect(session.Out.Contents()).To(ContainSubstring("warn"))
 		Expect(session.Out.Contents()).To(ContainSubstring("error"))
 		Expect(session.Out.Contents()).To(ContainSubstring("fatal"))
 	})
@@ -62,6 +63,7 @@ var _ = Describe("CF-Lager", func() {
 
 		Expect(session.Out.Contents()).To(ContainSubstring("debug"))
 		Expect(session.Out.Contents()).To(ContainSubstring("info"))
+		Expect(session.Out.Contents()).To(ContainSubstring("warn"))
 		Expect(session.Out.Contents()).To(ContainSubstring("error"))
 		Expect(session.Out.Contents()).To(ContainSubstring("fatal"))
 	})
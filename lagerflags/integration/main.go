@@ -16,6 +16,7 @@ func main() {
 
 	logger.Debug("component-does-action", lager.Data{"debug-detail": "foo"})
 	logger.Info("another-component-action", lager.Data{"info-detail": "bar"})
+	logger.Warn("component-noticed-something", lager.Data{"warn-detail": "qux"})
 	logger.Error("component-failed-something", errors.New("error"), lager.Data{"error-detail": "baz"})
 	logger.Fatal("component-failed-badly", errors.New("fatal"), lager.Data{"fatal-detail": "quux"})
 }
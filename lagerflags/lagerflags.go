@@ -1,9 +1,10 @@
 package lagerflags
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"os"
+	"regexp"
 
 	"code.cloudfoundry.org/lager/v3"
 )
@@ -11,6 +12,7 @@ import (
 const (
 	DEBUG = "debug"
 	INFO  = "info"
+	WARN  = "warn"
 	ERROR = "error"
 	FATAL = "fatal"
 )
@@ -21,6 +23,8 @@ type LagerConfig struct {
 	RedactPatterns      []string   `json:"redact_patterns,omitempty"`
 	TimeFormat          TimeFormat `json:"time_format"`
 	MaxDataStringLength int        `json:"max_data_string_length"`
+	// Outputs are where logs are written. Without any they go to stdout.
+	Outputs []LagerOutput `json:"outputs,omitempty"`
 }
 
 func DefaultLagerConfig() LagerConfig {
@@ -33,91 +37,259 @@ func DefaultLagerConfig() LagerConfig {
 	}
 }
 
-var minLogLevel string
-var redactSecrets bool
-var redactPatterns RedactPatterns
-var timeFormat TimeFormat
+// Flags holds the values of the lagerflags flags added to a FlagSet. Each
+// Flags is independent of the others and of the package-level AddFlags.
+type Flags struct {
+	flagSet *flag.FlagSet
+	config  LagerConfig
+}
+
+// AddFlags adds flags for every LagerConfig field to the flag set, defaulting
+// to DefaultLagerConfig.
+func (f *Flags) AddFlags(flagSet *flag.FlagSet) {
+	f.flagSet = flagSet
+	f.config = DefaultLagerConfig()
 
-func AddFlags(flagSet *flag.FlagSet) {
 	flagSet.StringVar(
-		&minLogLevel,
+		&f.config.LogLevel,
 		"logLevel",
 		string(INFO),
-		"log level: debug, info, error or fatal",
+		"log level: debug, info, warn, error or fatal",
 	)
 	flagSet.BoolVar(
-		&redactSecrets,
+		&f.config.RedactSecrets,
 		"redactSecrets",
 		false,
 		"use a redacting log sink to scrub sensitive values from data being logged",
 	)
 	flagSet.Var(
-		&redactPatterns,
+		(*RedactPatterns)(&f.config.RedactPatterns),
 		"redactPatterns",
 		"Regex patterns to use to determine sensitive values for redaction",
 	)
 	flagSet.Var(
-		&timeFormat,
+		&f.config.TimeFormat,
 		"timeFormat",
 		`Format for timestamp in component logs. Valid values are "unix-epoch" and "rfc3339".`,
 	)
+	flagSet.IntVar(
+		&f.config.MaxDataStringLength,
+		"maxDataStringLength",
+		0,
+		"truncate strings in log data longer than this many bytes, or 0 to never truncate",
+	)
+	flagSet.Var(
+		(*LagerOutputs)(&f.config.Outputs),
+		"logOutput",
+		`Where to write logs, given once per output: "stdout", "stderr", "file:<path>", "tcp://<address>" or "unix://<path>", `+
+			`optionally followed by "?format=json|logfmt|console&level=<log level>". Defaults to stdout.`,
+	)
+}
+
+// Config returns the config given by the flags.
+func (f *Flags) Config() LagerConfig {
+	return f.config
 }
 
+// Apply returns config with each field whose flag was given on the command
+// line replaced by the flag's value.
+func (f *Flags) Apply(config LagerConfig) LagerConfig {
+	return applyFlags(config, f.flagSet)
+}
+
+// applyFlags replaces each field of config whose flag was given in flagSet by
+// the value the flag holds.
+func applyFlags(config LagerConfig, flagSet *flag.FlagSet) LagerConfig {
+	if flagSet == nil {
+		return config
+	}
+
+	flagSet.Visit(func(given *flag.Flag) {
+		var value interface{} = given.Value
+		if getter, ok := given.Value.(flag.Getter); ok {
+			value = getter.Get()
+		}
+
+		switch v := value.(type) {
+		case string:
+			if given.Name == "logLevel" {
+				config.LogLevel = v
+			}
+		case bool:
+			if given.Name == "redactSecrets" {
+				config.RedactSecrets = v
+			}
+		case int:
+			if given.Name == "maxDataStringLength" {
+				config.MaxDataStringLength = v
+			}
+		case *RedactPatterns:
+			if given.Name == "redactPatterns" {
+				config.RedactPatterns = *v
+			}
+		case *TimeFormat:
+			if given.Name == "timeFormat" {
+				config.TimeFormat = *v
+			}
+		case *LagerOutputs:
+			if given.Name == "logOutput" {
+				config.Outputs = *v
+			}
+		}
+	})
+	return config
+}
+
+var defaultFlags Flags
+
+// AddFlags adds the lagerflags flags to the flag set, to be read by
+// ConfigFromFlags, New and TryNew. Use a Flags to add them to more than one
+// flag set.
+func AddFlags(flagSet *flag.FlagSet) {
+	defaultFlags = Flags{}
+	defaultFlags.AddFlags(flagSet)
+}
+
+// ConfigFromFlags returns the config given by the flags added by AddFlags.
 func ConfigFromFlags() LagerConfig {
-	return LagerConfig{
-		LogLevel:       minLogLevel,
-		RedactSecrets:  redactSecrets,
-		RedactPatterns: redactPatterns,
-		TimeFormat:     timeFormat,
+	return defaultFlags.Config()
+}
+
+// ApplyFlags returns config with each field whose flag was given in flagSet
+// replaced by the flag's value. The flags can have been added by AddFlags or
+// by a Flags.
+func ApplyFlags(config LagerConfig, flagSet *flag.FlagSet) LagerConfig {
+	return applyFlags(config, flagSet)
+}
+
+// Validate reports every field of the config that lagerflags cannot build a
+// logger from, joined into a single error.
+func (c LagerConfig) Validate() error {
+	var errs []error
+
+	if _, err := lager.LogLevelFromString(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log_level %q: must be one of debug, info, warn, error or fatal", c.LogLevel))
+	}
+
+	for i, pattern := range c.RedactPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid redact_patterns[%d]: %w", i, err))
+		}
+	}
+
+	if c.TimeFormat != FormatUnixEpoch && c.TimeFormat != FormatRFC3339 {
+		errs = append(errs, fmt.Errorf("invalid time_format: %d", c.TimeFormat))
+	}
+
+	if c.MaxDataStringLength < 0 {
+		errs = append(errs, fmt.Errorf("invalid max_data_string_length %d: must not be negative", c.MaxDataStringLength))
+	}
+
+	for i, output := range c.Outputs {
+		if err := output.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid outputs[%d]: %w", i, err))
+		}
 	}
+
+	return errors.Join(errs...)
 }
 
+// New is like TryNew but panics if the flags are invalid.
 func New(component string) (lager.Logger, *lager.ReconfigurableSink) {
-	return NewFromConfig(component, ConfigFromFlags())
+	return mustLogger(TryNew(component))
 }
 
+// NewFromSink is like TryNewFromSink but panics if the log level flag is
+// invalid.
 func NewFromSink(component string, sink lager.Sink) (lager.Logger, *lager.ReconfigurableSink) {
-	return newLogger(component, minLogLevel, sink)
+	return mustLogger(TryNewFromSink(component, sink))
 }
 
+// NewFromConfig is like TryNewFromConfig but panics if the config is invalid.
 func NewFromConfig(component string, config LagerConfig) (lager.Logger, *lager.ReconfigurableSink) {
-	var sink lager.Sink
+	return mustLogger(TryNewFromConfig(component, config))
+}
+
+// TryNew returns a logger configured by the flags added by AddFlags.
+func TryNew(component string) (lager.Logger, *lager.ReconfigurableSink, error) {
+	return TryNewFromConfig(component, ConfigFromFlags())
+}
+
+// TryNewFromSink returns a logger that writes to the sink at the level given
+// by the logLevel flag.
+func TryNewFromSink(component string, sink lager.Sink) (lager.Logger, *lager.ReconfigurableSink, error) {
+	return newLogger(component, defaultFlags.config.LogLevel, sink)
+}
+
+// TryNewFromConfig returns a logger that writes to the outputs described by
+// the config, or the errors from config.Validate. Closing the returned sink
+// closes the files and connections of the outputs.
+func TryNewFromConfig(component string, config LagerConfig) (lager.Logger, *lager.ReconfigurableSink, error) {
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	outputs, err := outputSink(config)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	if config.TimeFormat == FormatRFC3339 {
-		sink = lager.NewPrettySink(os.Stdout, lager.DEBUG)
-	} else {
-		sink = lager.NewWriterSink(os.Stdout, lager.DEBUG)
+	sink, err := filterSink(config, outputs)
+	if err != nil {
+		lager.CloseSink(outputs) //nolint:errcheck
+		return nil, nil, err
 	}
 
+	return newLogger(component, config.LogLevel, sink)
+}
+
+// filterSink wraps the outputs in the redacting and truncating sinks the
+// config asks for.
+func filterSink(config LagerConfig, sink lager.Sink) (lager.Sink, error) {
 	if config.RedactSecrets {
-		var err error
-		sink, err = lager.NewRedactingSink(sink, nil, config.RedactPatterns)
+		redactingSink, err := lager.NewRedactingSink(sink, nil, config.RedactPatterns)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
+		sink = redactingSink
 	}
 
 	if config.MaxDataStringLength > 0 {
 		sink = lager.NewTruncatingSink(sink, config.MaxDataStringLength)
 	}
 
-	return newLogger(component, config.LogLevel, sink)
+	return sink, nil
 }
 
-func newLogger(component, minLogLevel string, inSink lager.Sink) (lager.Logger, *lager.ReconfigurableSink) {
-	var minLagerLogLevel lager.LogLevel
+// outputSink returns a sink that writes to every output of the config.
+func outputSink(config LagerConfig) (lager.Sink, error) {
+	outputs := config.Outputs
+	if len(outputs) == 0 {
+		outputs = []LagerOutput{{Type: OutputStdout}}
+	}
 
-	switch minLogLevel {
-	case DEBUG:
-		minLagerLogLevel = lager.DEBUG
-	case INFO:
-		minLagerLogLevel = lager.INFO
-	case ERROR:
-		minLagerLogLevel = lager.ERROR
-	case FATAL:
-		minLagerLogLevel = lager.FATAL
-	default:
-		panic(fmt.Errorf("unknown log level: %s", minLogLevel))
+	routes := make([]lager.Route, 0, len(outputs))
+	for i, output := range outputs {
+		sink, err := output.sink(config.TimeFormat)
+		if err != nil {
+			for _, route := range routes {
+				lager.CloseSink(route.Sink) //nolint:errcheck
+			}
+			return nil, fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+		routes = append(routes, lager.Route{Sink: sink})
+	}
+
+	if len(routes) == 1 {
+		return routes[0].Sink, nil
+	}
+	return lager.NewRoutingSink(lager.RouteAllMatches, routes...), nil
+}
+
+func newLogger(component, minLogLevel string, inSink lager.Sink) (lager.Logger, *lager.ReconfigurableSink, error) {
+	minLagerLogLevel, err := lager.LogLevelFromString(minLogLevel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown log level: %s", minLogLevel)
 	}
 
 	logger := lager.NewLogger(component)
@@ -125,5 +297,12 @@ func newLogger(component, minLogLevel string, inSink lager.Sink) (lager.Logger,
 	sink := lager.NewReconfigurableSink(inSink, minLagerLogLevel)
 	logger.RegisterSink(sink)
 
+	return logger, sink, nil
+}
+
+func mustLogger(logger lager.Logger, sink *lager.ReconfigurableSink, err error) (lager.Logger, *lager.ReconfigurableSink) {
+	if err != nil {
+		panic(err)
+	}
 	return logger, sink
 }
@@ -62,6 +62,11 @@ var _ = Describe("Lagerflags", func() {
 					MaxDataStringLength: 0,
 				}))
 			})
+
+			It("includes the max data string length", func() {
+				Expect(flagSet.Parse([]string{"-maxDataStringLength", "50"})).To(Succeed())
+				Expect(lagerflags.ConfigFromFlags().MaxDataStringLength).To(Equal(50))
+			})
 		})
 
 		Describe("New", func() {
@@ -97,6 +102,65 @@ var _ = Describe("Lagerflags", func() {
 		})
 	})
 
+	Describe("Flags", func() {
+		newFlagSet := func(flags *lagerflags.Flags) *flag.FlagSet {
+			flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+			flagSet.SetOutput(io.Discard)
+			flags.AddFlags(flagSet)
+			return flagSet
+		}
+
+		It("binds every config field to the flags of its own flag set", func() {
+			var first, second lagerflags.Flags
+			firstSet := newFlagSet(&first)
+			secondSet := newFlagSet(&second)
+
+			Expect(firstSet.Parse([]string{
+				"-logLevel", "debug",
+				"-redactSecrets",
+				"-redactPatterns", "s3cr3t",
+				"-timeFormat", "rfc3339",
+				"-maxDataStringLength", "50",
+				"-logOutput", "stderr",
+			})).To(Succeed())
+			Expect(secondSet.Parse([]string{"-logLevel", "error"})).To(Succeed())
+
+			Expect(first.Config()).To(Equal(lagerflags.LagerConfig{
+				LogLevel:            lagerflags.DEBUG,
+				RedactSecrets:       true,
+				RedactPatterns:      []string{"s3cr3t"},
+				TimeFormat:          lagerflags.FormatRFC3339,
+				MaxDataStringLength: 50,
+				Outputs:             []lagerflags.LagerOutput{{Type: lagerflags.OutputStderr}},
+			}))
+
+			expected := lagerflags.DefaultLagerConfig()
+			expected.LogLevel = lagerflags.ERROR
+			Expect(second.Config()).To(Equal(expected))
+		})
+
+		It("does not change the flags added by AddFlags", func() {
+			lagerflags.AddFlags(flag.NewFlagSet("global", flag.ContinueOnError))
+
+			var flags lagerflags.Flags
+			Expect(newFlagSet(&flags).Parse([]string{"-logLevel", "fatal"})).To(Succeed())
+
+			Expect(lagerflags.ConfigFromFlags().LogLevel).To(Equal(lagerflags.INFO))
+		})
+
+		It("applies only the flags that were given to a config", func() {
+			var flags lagerflags.Flags
+			Expect(newFlagSet(&flags).Parse([]string{"-maxDataStringLength", "10"})).To(Succeed())
+
+			config := lagerflags.DefaultLagerConfig()
+			config.LogLevel = lagerflags.WARN
+
+			config = flags.Apply(config)
+			Expect(config.LogLevel).To(Equal(lagerflags.WARN))
+			Expect(config.MaxDataStringLength).To(Equal(10))
+		})
+	})
+
 	Describe("NewFromConfig", func() {
 		It("creates a logger that respects the log level", func() {
 			buf, origStdout := replaceStdoutWithBuf()
@@ -114,6 +178,23 @@ var _ = Describe("Lagerflags", func() {
 			Eventually(buf).Should(gbytes.Say("kaboom"))
 		})
 
+		It("creates a logger that respects the warn log level", func() {
+			buf, origStdout := replaceStdoutWithBuf()
+			defer func() {
+				os.Stdout = origStdout
+			}()
+
+			logger, sink := lagerflags.NewFromConfig("test", lagerflags.LagerConfig{
+				LogLevel: lagerflags.WARN,
+			})
+			Expect(sink.GetMinLevel()).To(Equal(lager.WARN))
+
+			logger.Info("hello")
+			Consistently(buf).ShouldNot(gbytes.Say("hello"))
+			logger.Warn("careful")
+			Eventually(buf).Should(gbytes.Say("careful"))
+		})
+
 		It("creates a logger that respects the time format settings", func() {
 			buf, origStdout := replaceStdoutWithBuf()
 			defer func() {
@@ -182,5 +263,75 @@ var _ = Describe("Lagerflags", func() {
 				})
 			}).To(Panic())
 		})
+
+		It("panics if a redaction pattern is invalid", func() {
+			Expect(func() {
+				_, _ = lagerflags.NewFromConfig("test", lagerflags.LagerConfig{
+					LogLevel:       lagerflags.INFO,
+					RedactSecrets:  true,
+					RedactPatterns: []string{"("},
+				})
+			}).To(Panic())
+		})
+	})
+
+	Describe("TryNewFromConfig", func() {
+		It("creates a logger from a valid config", func() {
+			logger, sink, err := lagerflags.TryNewFromConfig("test", lagerflags.DefaultLagerConfig())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logger).NotTo(BeNil())
+			Expect(sink.GetMinLevel()).To(Equal(lager.INFO))
+		})
+
+		It("returns an error instead of panicking when the config is invalid", func() {
+			logger, sink, err := lagerflags.TryNewFromConfig("test", lagerflags.LagerConfig{
+				LogLevel:       lagerflags.INFO,
+				RedactSecrets:  true,
+				RedactPatterns: []string{"("},
+			})
+			Expect(err).To(MatchError(ContainSubstring("invalid redact_patterns[0]")))
+			Expect(logger).To(BeNil())
+			Expect(sink).To(BeNil())
+		})
+	})
+
+	Describe("TryNewFromSink", func() {
+		It("creates a logger that writes to the sink", func() {
+			flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+			lagerflags.AddFlags(flagSet)
+			Expect(flagSet.Parse([]string{"-logLevel", "warn"})).To(Succeed())
+
+			buf := gbytes.NewBuffer()
+			logger, sink, err := lagerflags.TryNewFromSink("test", lager.NewWriterSink(buf, lager.DEBUG))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sink.GetMinLevel()).To(Equal(lager.WARN))
+
+			logger.Warn("careful")
+			Expect(buf).To(gbytes.Say("careful"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts the default config", func() {
+			Expect(lagerflags.DefaultLagerConfig().Validate()).To(Succeed())
+		})
+
+		It("reports every invalid field", func() {
+			err := lagerflags.LagerConfig{
+				LogLevel:            "loud",
+				RedactPatterns:      []string{"ok", "(", "[a-"},
+				TimeFormat:          lagerflags.TimeFormat(7),
+				MaxDataStringLength: -1,
+			}.Validate()
+
+			Expect(err).To(HaveOccurred())
+			Expect(strings.Split(err.Error(), "\n")).To(ConsistOf(
+				`invalid log_level "loud": must be one of debug, info, warn, error or fatal`,
+				HavePrefix("invalid redact_patterns[1]: "),
+				HavePrefix("invalid redact_patterns[2]: "),
+				"invalid time_format: 7",
+				"invalid max_data_string_length -1: must not be negative",
+			))
+		})
 	})
 })
@@ -0,0 +1,209 @@
+package lagerflags
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const maxLogLevelRequestSize = 1024
+
+// LogLevelHandler is an http.Handler for reading and changing the minimum log
+// level of a ReconfigurableSink at runtime.
+//
+// GET responds with the current level. PUT and POST set a new level given
+// either as a plain text body, e.g. "debug", or as a JSON body, e.g.
+// {"log_level": "debug", "duration": "10m"}. With a duration (which can also
+// be passed as a "duration" query parameter) the level reverts to the previous
+// one once the duration has passed, unless it has been changed elsewhere in the
+// meantime.
+type LogLevelHandler struct {
+	logger lager.Logger
+	sink   *lager.ReconfigurableSink
+
+	lock       sync.Mutex
+	revert     *time.Timer
+	revertFrom lager.LogLevel
+	revertTo   lager.LogLevel
+	revertAt   time.Time
+	generation uint64
+}
+
+type logLevelRequest struct {
+	LogLevel string `json:"log_level"`
+	Duration string `json:"duration,omitempty"`
+}
+
+type logLevelResponse struct {
+	LogLevel  string     `json:"log_level"`
+	RevertsTo string     `json:"reverts_to,omitempty"`
+	RevertsAt *time.Time `json:"reverts_at,omitempty"`
+}
+
+func NewLogLevelHandler(logger lager.Logger, sink *lager.ReconfigurableSink) *LogLevelHandler {
+	return &LogLevelHandler{
+		logger: logger.Session("log-level-handler"),
+		sink:   sink,
+	}
+}
+
+func (h *LogLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.writeStatus(w)
+	case http.MethodPut, http.MethodPost:
+		req, err := parseLogLevelRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		level, err := lager.LogLevelFromString(req.LogLevel)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var duration time.Duration
+		if req.Duration != "" {
+			duration, err = time.ParseDuration(req.Duration)
+			if err != nil || duration <= 0 {
+				http.Error(w, fmt.Sprintf("invalid duration: %s", req.Duration), http.StatusBadRequest)
+				return
+			}
+		}
+
+		h.setLevel(level, duration)
+		h.writeStatus(w)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *LogLevelHandler) setLevel(level lager.LogLevel, duration time.Duration) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.generation++
+
+	previous := h.sink.GetMinLevel()
+	revertTo := previous
+	if h.reverting() {
+		// A temporary change is already in place, so keep reverting to the
+		// level from before it
+		revertTo = h.revertTo
+	}
+	if h.revert != nil {
+		h.revert.Stop()
+		h.revert = nil
+	}
+
+	data := lager.Data{"from": previous.String(), "to": level.String()}
+	if duration > 0 {
+		h.revertFrom = level
+		h.revertTo = revertTo
+		h.revertAt = time.Now().Add(duration)
+		generation := h.generation
+		h.revert = time.AfterFunc(duration, func() { h.revertLevel(generation) })
+		data["duration"] = duration.String()
+		data["reverts-to"] = revertTo.String()
+	}
+
+	h.changeLevel(previous, level, "log-level-changed", data)
+}
+
+func (h *LogLevelHandler) revertLevel(generation uint64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	// The level has been changed again since this revert was scheduled
+	if h.generation != generation {
+		return
+	}
+	reverting := h.reverting()
+	h.revert = nil
+
+	// The level has been changed elsewhere, e.g. by a ConfigWatcher, which
+	// takes precedence over the temporary change
+	if !reverting {
+		return
+	}
+
+	previous := h.sink.GetMinLevel()
+	h.changeLevel(previous, h.revertTo, "log-level-reverted", lager.Data{"from": previous.String(), "to": h.revertTo.String()})
+}
+
+// reverting reports whether a temporary change is still in place. The caller
+// must hold the lock.
+func (h *LogLevelHandler) reverting() bool {
+	return h.revert != nil && h.sink.GetMinLevel() == h.revertFrom
+}
+
+// changeLevel sets the level and logs the change. The logger may write
+// through the sink being changed, so the change is logged before the level is
+// raised and after it is lowered, at a level the sink lets through.
+func (h *LogLevelHandler) changeLevel(from, to lager.LogLevel, message string, data lager.Data) {
+	if to > from {
+		logAtLeast(h.logger, from, message, data)
+		h.sink.SetMinLevel(to)
+		return
+	}
+
+	h.sink.SetMinLevel(to)
+	logAtLeast(h.logger, to, message, data)
+}
+
+// logAtLeast logs at info, or at level when that is higher, so that a sink
+// whose minimum is level lets the log through. Levels above error are logged
+// at error, since Fatal does not return.
+func logAtLeast(logger lager.Logger, level lager.LogLevel, message string, data lager.Data) {
+	switch {
+	case level <= lager.INFO:
+		logger.Info(message, data)
+	case level == lager.WARN:
+		logger.Warn(message, data)
+	default:
+		logger.Error(message, nil, data)
+	}
+}
+
+func (h *LogLevelHandler) writeStatus(w http.ResponseWriter) {
+	h.lock.Lock()
+	resp := logLevelResponse{LogLevel: h.sink.GetMinLevel().String()}
+	if h.reverting() {
+		revertAt := h.revertAt.UTC()
+		resp.RevertsTo = h.revertTo.String()
+		resp.RevertsAt = &revertAt
+	}
+	h.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp) //nolint:errcheck
+}
+
+func parseLogLevelRequest(r *http.Request) (logLevelRequest, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxLogLevelRequestSize))
+	if err != nil {
+		return logLevelRequest{}, err
+	}
+
+	req := logLevelRequest{Duration: r.URL.Query().Get("duration")}
+
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal([]byte(trimmed), &req); err != nil {
+			return logLevelRequest{}, fmt.Errorf("invalid request body: %w", err)
+		}
+	} else {
+		req.LogLevel = trimmed
+	}
+
+	return req, nil
+}
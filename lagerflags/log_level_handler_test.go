@@ -0,0 +1,211 @@
+package lagerflags_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagerflags"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+)
+
+var _ = Describe("LogLevelHandler", func() {
+	var (
+		logger  *lagertest.TestLogger
+		sink    *lager.ReconfigurableSink
+		handler http.Handler
+	)
+
+	serve := func(method, target, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+		var resp map[string]interface{}
+		if recorder.Code == http.StatusOK {
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+		}
+		return recorder, resp
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG), lager.INFO)
+		handler = lagerflags.NewLogLevelHandler(logger, sink)
+	})
+
+	It("reports the current level", func() {
+		recorder, resp := serve(http.MethodGet, "/log-level", "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(resp).To(Equal(map[string]interface{}{"log_level": "info"}))
+	})
+
+	It("sets the level from a plain text body", func() {
+		recorder, resp := serve(http.MethodPut, "/log-level", "debug\n")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(resp).To(HaveKeyWithValue("log_level", "debug"))
+		Expect(sink.GetMinLevel()).To(Equal(lager.DEBUG))
+	})
+
+	It("sets the level from a JSON body", func() {
+		recorder, _ := serve(http.MethodPost, "/log-level", `{"log_level": "warn"}`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(sink.GetMinLevel()).To(Equal(lager.WARN))
+	})
+
+	It("logs the change", func() {
+		serve(http.MethodPut, "/log-level", "error")
+
+		logs := logger.Logs()
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0].Message).To(Equal("test.log-level-handler.log-level-changed"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("from", "info"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("to", "error"))
+	})
+
+	It("rejects an invalid level", func() {
+		recorder, _ := serve(http.MethodPut, "/log-level", "loud")
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring("invalid log level: loud"))
+		Expect(sink.GetMinLevel()).To(Equal(lager.INFO))
+	})
+
+	It("rejects an invalid JSON body", func() {
+		recorder, _ := serve(http.MethodPut, "/log-level", `{"log_level":`)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("rejects an invalid duration", func() {
+		recorder, _ := serve(http.MethodPut, "/log-level?duration=forever", "debug")
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(sink.GetMinLevel()).To(Equal(lager.INFO))
+	})
+
+	It("rejects other methods", func() {
+		recorder, _ := serve(http.MethodDelete, "/log-level", "")
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(recorder.Header().Get("Allow")).To(Equal("GET, PUT, POST"))
+	})
+
+	Context("when the logger writes through the sink it changes", func() {
+		var testSink *lagertest.TestSink
+
+		BeforeEach(func() {
+			testSink = lagertest.NewTestSink()
+			sink = lager.NewReconfigurableSink(testSink, lager.INFO)
+			ownLogger := lager.NewLogger("test")
+			ownLogger.RegisterSink(sink)
+			handler = lagerflags.NewLogLevelHandler(ownLogger, sink)
+		})
+
+		It("logs a change that raises the level", func() {
+			serve(http.MethodPut, "/log-level", "error")
+
+			Expect(sink.GetMinLevel()).To(Equal(lager.ERROR))
+			Expect(testSink.LogMessages()).To(Equal([]string{"test.log-level-handler.log-level-changed"}))
+		})
+
+		It("logs a change that lowers the level", func() {
+			sink.SetMinLevel(lager.ERROR)
+			serve(http.MethodPut, "/log-level", "info")
+
+			Expect(testSink.LogMessages()).To(Equal([]string{"test.log-level-handler.log-level-changed"}))
+		})
+
+		It("logs changes between levels above info", func() {
+			sink.SetMinLevel(lager.ERROR)
+			serve(http.MethodPut, "/log-level", "fatal")
+			serve(http.MethodPut, "/log-level", "warn")
+
+			Expect(testSink.LogMessages()).To(Equal([]string{
+				"test.log-level-handler.log-level-changed",
+				"test.log-level-handler.log-level-changed",
+			}))
+			logs := testSink.Logs()
+			Expect(logs[0].LogLevel).To(Equal(lager.ERROR))
+			Expect(logs[0].Data).To(HaveKeyWithValue("to", "fatal"))
+			Expect(logs[1].LogLevel).To(Equal(lager.WARN))
+			Expect(logs[1].Data).To(HaveKeyWithValue("to", "warn"))
+		})
+
+		It("logs reverting both ways", func() {
+			serve(http.MethodPut, "/log-level?duration=50ms", "error")
+			Eventually(sink.GetMinLevel).Should(Equal(lager.INFO))
+
+			serve(http.MethodPut, "/log-level?duration=50ms", "debug")
+			Eventually(sink.GetMinLevel).Should(Equal(lager.INFO))
+
+			Eventually(testSink.LogMessages).Should(Equal([]string{
+				"test.log-level-handler.log-level-changed",
+				"test.log-level-handler.log-level-reverted",
+				"test.log-level-handler.log-level-changed",
+				"test.log-level-handler.log-level-reverted",
+			}))
+		})
+	})
+
+	Context("with a duration", func() {
+		It("reverts to the previous level once the duration has passed", func() {
+			recorder, resp := serve(http.MethodPut, "/log-level?duration=100ms", "debug")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(resp).To(HaveKeyWithValue("log_level", "debug"))
+			Expect(resp).To(HaveKeyWithValue("reverts_to", "info"))
+			Expect(resp).To(HaveKey("reverts_at"))
+			Expect(sink.GetMinLevel()).To(Equal(lager.DEBUG))
+
+			Eventually(sink.GetMinLevel).Should(Equal(lager.INFO))
+			Expect(logger.LogMessages()).To(ContainElement("test.log-level-handler.log-level-reverted"))
+
+			_, resp = serve(http.MethodGet, "/log-level", "")
+			Expect(resp).To(Equal(map[string]interface{}{"log_level": "info"}))
+		})
+
+		It("accepts the duration in a JSON body", func() {
+			_, resp := serve(http.MethodPut, "/log-level", `{"log_level": "debug", "duration": "1h"}`)
+			Expect(resp).To(HaveKeyWithValue("reverts_to", "info"))
+		})
+
+		It("keeps reverting to the original level when changed again", func() {
+			serve(http.MethodPut, "/log-level?duration=1h", "debug")
+			_, resp := serve(http.MethodPut, "/log-level?duration=100ms", "error")
+			Expect(resp).To(HaveKeyWithValue("reverts_to", "info"))
+
+			Eventually(sink.GetMinLevel).Should(Equal(lager.INFO))
+		})
+
+		It("does not revert a level changed elsewhere", func() {
+			serve(http.MethodPut, "/log-level?duration=100ms", "debug")
+			sink.SetMinLevel(lager.ERROR)
+
+			_, resp := serve(http.MethodGet, "/log-level", "")
+			Expect(resp).To(Equal(map[string]interface{}{"log_level": "error"}))
+
+			Consistently(sink.GetMinLevel, 300*time.Millisecond).Should(Equal(lager.ERROR))
+			Expect(logger.LogMessages()).NotTo(ContainElement("test.log-level-handler.log-level-reverted"))
+		})
+
+		It("reverts to the level set elsewhere after a new temporary change", func() {
+			serve(http.MethodPut, "/log-level?duration=1h", "debug")
+			sink.SetMinLevel(lager.WARN)
+
+			_, resp := serve(http.MethodPut, "/log-level?duration=100ms", "debug")
+			Expect(resp).To(HaveKeyWithValue("reverts_to", "warn"))
+
+			Eventually(sink.GetMinLevel).Should(Equal(lager.WARN))
+		})
+
+		It("is cancelled by a permanent change", func() {
+			serve(http.MethodPut, "/log-level?duration=100ms", "debug")
+			_, resp := serve(http.MethodPut, "/log-level", "error")
+			Expect(resp).NotTo(HaveKey("reverts_to"))
+
+			Consistently(sink.GetMinLevel, 300*time.Millisecond).Should(Equal(lager.ERROR))
+		})
+	})
+})
@@ -0,0 +1,287 @@
+package lagerflags
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	OutputStdout  = "stdout"
+	OutputStderr  = "stderr"
+	OutputFile    = "file"
+	OutputNetwork = "network"
+
+	FormatJSON    = "json"
+	FormatLogfmt  = "logfmt"
+	FormatConsole = "console"
+)
+
+// LagerOutput describes one destination for logs.
+type LagerOutput struct {
+	// Type is "stdout", "stderr", "file" or "network".
+	Type string `json:"type"`
+	// Path is the file written by "file" outputs.
+	Path string `json:"path,omitempty"`
+	// Network is "tcp" or "unix" for "network" outputs, and defaults to
+	// "tcp".
+	Network string `json:"network,omitempty"`
+	// Address is the host:port, or socket path, of "network" outputs.
+	Address string `json:"address,omitempty"`
+	// Format is "json" (the default), "logfmt" or "console". JSON and logfmt
+	// timestamps follow the config's time_format. Network outputs only
+	// write JSON.
+	Format string `json:"format,omitempty"`
+	// LogLevel is the minimum level written to this output, on top of the
+	// config's log_level. Empty writes every level the logger logs.
+	LogLevel string `json:"log_level,omitempty"`
+
+	// The rest configure the rotation of "file" outputs, as the fields of
+	// lager.FileSinkConfig do. RotateInterval is a duration such as "24h".
+	MaxSize        int64  `json:"max_size,omitempty"`
+	RotateInterval string `json:"rotate_interval,omitempty"`
+	MaxBackups     int    `json:"max_backups,omitempty"`
+	Compress       bool   `json:"compress,omitempty"`
+	ReopenOnSIGHUP bool   `json:"reopen_on_sighup,omitempty"`
+}
+
+// Validate reports the first problem with the output.
+func (o LagerOutput) Validate() error {
+	switch o.Type {
+	case OutputStdout, OutputStderr:
+	case OutputFile:
+		if o.Path == "" {
+			return errors.New("file outputs need a path")
+		}
+	case OutputNetwork:
+		if o.Network != "" && o.Network != "tcp" && o.Network != "unix" {
+			return fmt.Errorf("unknown network %q: must be tcp or unix", o.Network)
+		}
+		if o.Address == "" {
+			return errors.New("network outputs need an address")
+		}
+		if o.Format != "" && o.Format != FormatJSON {
+			return fmt.Errorf("network outputs only write %s", FormatJSON)
+		}
+	default:
+		return fmt.Errorf("unknown type %q: must be stdout, stderr, file or network", o.Type)
+	}
+
+	switch o.Format {
+	case "", FormatJSON, FormatLogfmt, FormatConsole:
+	default:
+		return fmt.Errorf("unknown format %q: must be json, logfmt or console", o.Format)
+	}
+
+	if o.LogLevel != "" {
+		if _, err := lager.LogLevelFromString(o.LogLevel); err != nil {
+			return fmt.Errorf("invalid log_level %q", o.LogLevel)
+		}
+	}
+
+	rotates := o.MaxSize != 0 || o.RotateInterval != "" || o.MaxBackups != 0 || o.Compress || o.ReopenOnSIGHUP
+	if rotates && o.Type != OutputFile {
+		return errors.New("only file outputs rotate")
+	}
+	if o.MaxSize < 0 {
+		return fmt.Errorf("invalid max_size %d: must not be negative", o.MaxSize)
+	}
+	if o.MaxBackups < 0 {
+		return fmt.Errorf("invalid max_backups %d: must not be negative", o.MaxBackups)
+	}
+	if o.RotateInterval != "" {
+		if interval, err := time.ParseDuration(o.RotateInterval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid rotate_interval %q", o.RotateInterval)
+		}
+	}
+
+	return nil
+}
+
+// String formats the output the way the logOutput flag takes it, e.g.
+// "stderr", "file:/var/log/app.log?format=logfmt" or
+// "tcp://logs.example.com:6514?level=error".
+func (o LagerOutput) String() string {
+	var s string
+	switch o.Type {
+	case OutputFile:
+		s = "file:" + o.Path
+	case OutputNetwork:
+		network := o.Network
+		if network == "" {
+			network = "tcp"
+		}
+		s = network + "://" + o.Address
+	default:
+		s = o.Type
+	}
+
+	query := url.Values{}
+	if o.Format != "" {
+		query.Set("format", o.Format)
+	}
+	if o.LogLevel != "" {
+		query.Set("level", o.LogLevel)
+	}
+	if o.MaxSize != 0 {
+		query.Set("max_size", strconv.FormatInt(o.MaxSize, 10))
+	}
+	if o.RotateInterval != "" {
+		query.Set("rotate_interval", o.RotateInterval)
+	}
+	if o.MaxBackups != 0 {
+		query.Set("max_backups", strconv.Itoa(o.MaxBackups))
+	}
+	if o.Compress {
+		query.Set("compress", "true")
+	}
+	if o.ReopenOnSIGHUP {
+		query.Set("reopen_on_sighup", "true")
+	}
+	if len(query) > 0 {
+		s += "?" + query.Encode()
+	}
+	return s
+}
+
+// ParseLagerOutput parses an output in the format of LagerOutput.String.
+func ParseLagerOutput(s string) (LagerOutput, error) {
+	dest, rawQuery, _ := strings.Cut(s, "?")
+
+	var output LagerOutput
+	switch {
+	case dest == OutputStdout || dest == OutputStderr:
+		output.Type = dest
+	case strings.HasPrefix(dest, "file:"):
+		output.Type = OutputFile
+		output.Path = strings.TrimPrefix(dest, "file:")
+	case strings.HasPrefix(dest, "tcp://"), strings.HasPrefix(dest, "unix://"):
+		output.Type = OutputNetwork
+		output.Network, output.Address, _ = strings.Cut(dest, "://")
+	default:
+		return LagerOutput{}, fmt.Errorf("invalid log output %q: must be stdout, stderr, file:<path>, tcp://<address> or unix://<path>", s)
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return LagerOutput{}, fmt.Errorf("invalid log output %q: %w", s, err)
+	}
+	for key := range query {
+		value := query.Get(key)
+		switch key {
+		case "format":
+			output.Format = value
+		case "level":
+			output.LogLevel = value
+		case "max_size":
+			output.MaxSize, err = strconv.ParseInt(value, 10, 64)
+		case "rotate_interval":
+			output.RotateInterval = value
+		case "max_backups":
+			output.MaxBackups, err = strconv.Atoi(value)
+		case "compress":
+			output.Compress, err = strconv.ParseBool(value)
+		case "reopen_on_sighup":
+			output.ReopenOnSIGHUP, err = strconv.ParseBool(value)
+		default:
+			return LagerOutput{}, fmt.Errorf("invalid log output %q: unknown option %q", s, key)
+		}
+		if err != nil {
+			return LagerOutput{}, fmt.Errorf("invalid log output %q: invalid %s %q", s, key, value)
+		}
+	}
+
+	if err := output.Validate(); err != nil {
+		return LagerOutput{}, fmt.Errorf("invalid log output %q: %w", s, err)
+	}
+	return output, nil
+}
+
+// LagerOutputs is a flag.Value that adds an output each time the flag is
+// given.
+type LagerOutputs []LagerOutput
+
+func (o *LagerOutputs) String() string {
+	outputs := make([]string, len(*o))
+	for i, output := range *o {
+		outputs[i] = output.String()
+	}
+	return strings.Join(outputs, ",")
+}
+
+func (o *LagerOutputs) Set(value string) error {
+	output, err := ParseLagerOutput(value)
+	if err != nil {
+		return err
+	}
+	*o = append(*o, output)
+	return nil
+}
+
+// sink returns a sink that writes to the output. Sinks for files and network
+// addresses implement lager.Closer, and file sinks are lager.FileSinks.
+func (o LagerOutput) sink(timeFormat TimeFormat) (lager.Sink, error) {
+	minLogLevel := lager.DEBUG
+	if o.LogLevel != "" {
+		minLogLevel, _ = lager.LogLevelFromString(o.LogLevel)
+	}
+
+	switch o.Type {
+	case OutputStderr:
+		return formatSink(os.Stderr, o.Format, timeFormat, minLogLevel), nil
+	case OutputFile:
+		config := lager.FileSinkConfig{
+			Path:           o.Path,
+			MinLogLevel:    minLogLevel,
+			Pretty:         timeFormat == FormatRFC3339,
+			MaxSize:        o.MaxSize,
+			MaxBackups:     o.MaxBackups,
+			Compress:       o.Compress,
+			ReopenOnSIGHUP: o.ReopenOnSIGHUP,
+		}
+		switch o.Format {
+		case FormatLogfmt:
+			config.Format = lager.FileFormatLogfmt
+		case FormatConsole:
+			config.Format = lager.FileFormatConsole
+		}
+		if o.RotateInterval != "" {
+			config.RotateInterval, _ = time.ParseDuration(o.RotateInterval)
+		}
+		return lager.NewFileSink(config)
+	case OutputNetwork:
+		network := o.Network
+		if network == "" {
+			network = "tcp"
+		}
+		return lager.NewNetworkSink(lager.NetworkSinkConfig{
+			Network:     network,
+			Address:     o.Address,
+			MinLogLevel: minLogLevel,
+		})
+	default:
+		return formatSink(os.Stdout, o.Format, timeFormat, minLogLevel), nil
+	}
+}
+
+func formatSink(writer io.Writer, format string, timeFormat TimeFormat, minLogLevel lager.LogLevel) lager.Sink {
+	switch {
+	case format == FormatConsole:
+		return lager.NewConsoleSink(writer, lager.ConsoleSinkConfig{MinLogLevel: minLogLevel})
+	case format == FormatLogfmt && timeFormat == FormatRFC3339:
+		return lager.NewPrettyLogfmtSink(writer, minLogLevel)
+	case format == FormatLogfmt:
+		return lager.NewLogfmtSink(writer, minLogLevel)
+	case timeFormat == FormatRFC3339:
+		return lager.NewPrettySink(writer, minLogLevel)
+	default:
+		return lager.NewWriterSink(writer, minLogLevel)
+	}
+}
@@ -0,0 +1,275 @@
+package lagerflags_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagerflags"
+)
+
+var _ = Describe("Outputs", func() {
+	Describe("ParseLagerOutput", func() {
+		DescribeTable("parses outputs",
+			func(s string, expected lagerflags.LagerOutput) {
+				output, err := lagerflags.ParseLagerOutput(s)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output).To(Equal(expected))
+				Expect(output.String()).To(Equal(s))
+			},
+			Entry("stdout", "stdout", lagerflags.LagerOutput{Type: lagerflags.OutputStdout}),
+			Entry("stderr with options", "stderr?format=console&level=debug", lagerflags.LagerOutput{
+				Type:     lagerflags.OutputStderr,
+				Format:   lagerflags.FormatConsole,
+				LogLevel: lagerflags.DEBUG,
+			}),
+			Entry("a file", "file:/var/log/app.log?format=logfmt", lagerflags.LagerOutput{
+				Type:   lagerflags.OutputFile,
+				Path:   "/var/log/app.log",
+				Format: lagerflags.FormatLogfmt,
+			}),
+			Entry("a tcp address", "tcp://logs.example.com:6514?level=error", lagerflags.LagerOutput{
+				Type:     lagerflags.OutputNetwork,
+				Network:  "tcp",
+				Address:  "logs.example.com:6514",
+				LogLevel: lagerflags.ERROR,
+			}),
+			Entry("a rotated file", "file:/var/log/app.log?compress=true&max_backups=5&max_size=1048576&reopen_on_sighup=true&rotate_interval=24h", lagerflags.LagerOutput{
+				Type:           lagerflags.OutputFile,
+				Path:           "/var/log/app.log",
+				MaxSize:        1048576,
+				RotateInterval: "24h",
+				MaxBackups:     5,
+				Compress:       true,
+				ReopenOnSIGHUP: true,
+			}),
+			Entry("a unix socket", "unix:///var/run/logs.sock", lagerflags.LagerOutput{
+				Type:    lagerflags.OutputNetwork,
+				Network: "unix",
+				Address: "/var/run/logs.sock",
+			}),
+		)
+
+		DescribeTable("rejects invalid outputs",
+			func(s string, message string) {
+				_, err := lagerflags.ParseLagerOutput(s)
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("an unknown destination", "syslog", "must be stdout, stderr"),
+			Entry("a file without a path", "file:", "file outputs need a path"),
+			Entry("an unknown format", "stdout?format=xml", `unknown format "xml"`),
+			Entry("an unknown level", "stdout?level=loud", `invalid log_level "loud"`),
+			Entry("an unknown option", "stdout?color=always", `unknown option "color"`),
+			Entry("a network output in another format", "tcp://localhost:1234?format=logfmt", "only write json"),
+			Entry("rotation for another output", "stdout?max_size=100", "only file outputs rotate"),
+			Entry("a malformed size", "file:/tmp/app.log?max_size=big", `invalid max_size "big"`),
+			Entry("a negative size", "file:/tmp/app.log?max_size=-1", "invalid max_size -1"),
+			Entry("a malformed interval", "file:/tmp/app.log?rotate_interval=daily", `invalid rotate_interval "daily"`),
+		)
+	})
+
+	It("adds an output each time the flag is given", func() {
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		flagSet.SetOutput(nopWriter{})
+		lagerflags.AddFlags(flagSet)
+
+		Expect(flagSet.Parse([]string{"-logOutput", "stdout", "-logOutput", "file:/tmp/app.log?level=warn"})).To(Succeed())
+		Expect(lagerflags.ConfigFromFlags().Outputs).To(Equal([]lagerflags.LagerOutput{
+			{Type: lagerflags.OutputStdout},
+			{Type: lagerflags.OutputFile, Path: "/tmp/app.log", LogLevel: lagerflags.WARN},
+		}))
+
+		Expect(flagSet.Parse([]string{"-logOutput", "nowhere"})).NotTo(Succeed())
+	})
+
+	It("reads outputs from JSON config", func() {
+		var config lagerflags.LagerConfig
+		Expect(json.Unmarshal([]byte(`{
+			"log_level": "info",
+			"outputs": [
+				{"type": "stderr", "format": "console"},
+				{"type": "network", "address": "logs.example.com:6514", "log_level": "error"}
+			]
+		}`), &config)).To(Succeed())
+
+		Expect(config.Outputs).To(Equal([]lagerflags.LagerOutput{
+			{Type: lagerflags.OutputStderr, Format: lagerflags.FormatConsole},
+			{Type: lagerflags.OutputNetwork, Address: "logs.example.com:6514", LogLevel: lagerflags.ERROR},
+		}))
+		Expect(config.Validate()).To(Succeed())
+	})
+
+	It("reports invalid outputs when validating the config", func() {
+		config := lagerflags.DefaultLagerConfig()
+		config.Outputs = []lagerflags.LagerOutput{{Type: lagerflags.OutputStdout}, {Type: "printer"}}
+
+		Expect(config.Validate()).To(MatchError(`invalid outputs[1]: unknown type "printer": must be stdout, stderr, file or network`))
+	})
+
+	Describe("NewFromConfig", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+		})
+
+		It("writes to every output in its own format and at its own level", func() {
+			buf, origStdout := replaceStdoutWithBuf()
+			defer func() {
+				os.Stdout = origStdout
+			}()
+
+			path := filepath.Join(dir, "app.log")
+			logger, sink := lagerflags.NewFromConfig("test", lagerflags.LagerConfig{
+				LogLevel: lagerflags.INFO,
+				Outputs: []lagerflags.LagerOutput{
+					{Type: lagerflags.OutputStdout, LogLevel: lagerflags.ERROR},
+					{Type: lagerflags.OutputFile, Path: path, Format: lagerflags.FormatLogfmt},
+				},
+			})
+
+			logger.Debug("hidden")
+			logger.Info("hello")
+			logger.Error("failed", errors.New("kaboom"))
+			Expect(sink.Close()).To(Succeed())
+
+			Eventually(buf).Should(gbytes.Say(`"message":"test.failed"`))
+			Consistently(buf).ShouldNot(gbytes.Say("hello"))
+
+			contents, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(MatchRegexp(`message=test\.hello log_level=1\n.*message=test\.failed log_level=2 error=kaboom\n$`))
+		})
+
+		It("redacts and truncates data written to every output", func() {
+			first := filepath.Join(dir, "first.log")
+			second := filepath.Join(dir, "second.log")
+			logger, sink := lagerflags.NewFromConfig("test", lagerflags.LagerConfig{
+				LogLevel:            lagerflags.INFO,
+				RedactSecrets:       true,
+				MaxDataStringLength: 20,
+				Outputs: []lagerflags.LagerOutput{
+					{Type: lagerflags.OutputFile, Path: first},
+					{Type: lagerflags.OutputFile, Path: second},
+				},
+			})
+
+			logger.Info("hello", lager.Data{"password": "secret", "long": "aaaaaaaaaaaaaaaaaaaaaaaaa"})
+			Expect(sink.Close()).To(Succeed())
+
+			for _, path := range []string{first, second} {
+				contents, err := os.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(ContainSubstring(`"password":"*REDACTED*"`))
+				Expect(string(contents)).To(ContainSubstring(`"long":"aaaaaaaa-(truncated)"`))
+			}
+		})
+
+		It("streams JSON to network outputs", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+
+			lines := make(chan string, 10)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+
+			logger, sink := lagerflags.NewFromConfig("test", lagerflags.LagerConfig{
+				LogLevel: lagerflags.INFO,
+				Outputs: []lagerflags.LagerOutput{
+					{Type: lagerflags.OutputNetwork, Address: listener.Addr().String()},
+				},
+			})
+			defer sink.Close()
+
+			logger.Info("hello")
+			Eventually(lines).Should(Receive(ContainSubstring(`"message":"test.hello"`)))
+		})
+
+		It("creates the directories of file outputs", func() {
+			path := filepath.Join(dir, "logs", "app.log")
+			logger, sink := lagerflags.NewFromConfig("test", lagerflags.LagerConfig{
+				LogLevel: lagerflags.INFO,
+				Outputs:  []lagerflags.LagerOutput{{Type: lagerflags.OutputFile, Path: path}},
+			})
+
+			logger.Info("hello")
+			Expect(sink.Close()).To(Succeed())
+
+			contents, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring(`"message":"test.hello"`))
+		})
+
+		It("rotates file outputs", func() {
+			path := filepath.Join(dir, "app.log")
+			logger, sink := lagerflags.NewFromConfig("test", lagerflags.LagerConfig{
+				LogLevel: lagerflags.INFO,
+				Outputs:  []lagerflags.LagerOutput{{Type: lagerflags.OutputFile, Path: path, MaxSize: 100}},
+			})
+
+			for i := 0; i < 3; i++ {
+				logger.Info(strings.Repeat("a", 50))
+			}
+			Expect(sink.Close()).To(Succeed())
+
+			backups, err := filepath.Glob(path + ".*")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backups).To(HaveLen(2))
+		})
+
+		It("reopens file outputs on SIGHUP when asked to", func() {
+			path := filepath.Join(dir, "app.log")
+			logger, sink := lagerflags.NewFromConfig("test", lagerflags.LagerConfig{
+				LogLevel: lagerflags.INFO,
+				Outputs:  []lagerflags.LagerOutput{{Type: lagerflags.OutputFile, Path: path, ReopenOnSIGHUP: true}},
+			})
+			defer sink.Close()
+
+			logger.Info("before")
+			Expect(os.Rename(path, path+".old")).To(Succeed())
+			process, err := os.FindProcess(os.Getpid())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(process.Signal(syscall.SIGHUP)).To(Succeed())
+
+			Eventually(func() string {
+				logger.Info("after")
+				contents, _ := os.ReadFile(path)
+				return string(contents)
+			}).Should(ContainSubstring(`"message":"test.after"`))
+		})
+
+		It("returns an error when a file cannot be opened", func() {
+			blocker := filepath.Join(dir, "blocker")
+			Expect(os.WriteFile(blocker, nil, 0644)).To(Succeed())
+
+			_, _, err := lagerflags.TryNewFromConfig("test", lagerflags.LagerConfig{
+				LogLevel: lagerflags.INFO,
+				Outputs: []lagerflags.LagerOutput{
+					{Type: lagerflags.OutputFile, Path: filepath.Join(blocker, "app.log")},
+				},
+			})
+			Expect(err).To(MatchError(HavePrefix("outputs[0]: ")))
+		})
+	})
+})
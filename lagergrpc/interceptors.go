@@ -0,0 +1,256 @@
+// Package lagergrpc provides gRPC interceptors that give each call its own
+// Lager session, the same way lagerhttp does for HTTP requests.
+package lagergrpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagerctx"
+)
+
+const (
+	// DefaultServerSessionName is the name of the session opened for each
+	// call a server receives when InterceptorConfig.SessionName is not set.
+	DefaultServerSessionName = "grpc-request"
+	// DefaultClientSessionName is the name of the session opened for each
+	// call a client makes when InterceptorConfig.SessionName is not set.
+	DefaultClientSessionName = "grpc-call"
+)
+
+// InterceptorConfig controls how the interceptors log calls.
+type InterceptorConfig struct {
+	// SessionName returns the name of the session opened for a call to the
+	// method, e.g. "/grpc.health.v1.Health/Check".
+	SessionName func(fullMethod string) string
+
+	// ExcludeMethods are full method names, such as health checks, that are
+	// not logged when they start and finish. Server handlers still get a
+	// logger in the call context.
+	ExcludeMethods []string
+}
+
+type interceptor struct {
+	logger       lager.Logger
+	config       InterceptorConfig
+	sessionName  string
+	startMessage string
+	excluded     map[string]struct{}
+}
+
+func newInterceptor(logger lager.Logger, config InterceptorConfig, sessionName, startMessage string) *interceptor {
+	excluded := make(map[string]struct{}, len(config.ExcludeMethods))
+	for _, method := range config.ExcludeMethods {
+		excluded[method] = struct{}{}
+	}
+
+	return &interceptor{
+		logger:       logger,
+		config:       config,
+		sessionName:  sessionName,
+		startMessage: startMessage,
+		excluded:     excluded,
+	}
+}
+
+func (i *interceptor) session(fullMethod string) lager.Logger {
+	name := i.sessionName
+	if i.config.SessionName != nil {
+		name = i.config.SessionName(fullMethod)
+	}
+	return i.logger.Session(name, lager.Data{"method": fullMethod})
+}
+
+func (i *interceptor) quiet(fullMethod string) bool {
+	_, ok := i.excluded[fullMethod]
+	return ok
+}
+
+// serverContext opens the session for a call and stores it, with the trace
+// from the incoming metadata, in the context.
+func (i *interceptor) serverContext(ctx context.Context, fullMethod string) (context.Context, lager.Logger) {
+	logger := i.session(fullMethod)
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		// metadata keys are lowercase, http.Header canonicalizes them so
+		// that NewTraceInfo finds them
+		header := http.Header{}
+		for key, values := range md {
+			for _, value := range values {
+				header.Add(key, value)
+			}
+		}
+
+		if trace, ok := lager.NewTraceInfo(&http.Request{Header: header}); ok {
+			logger = logger.WithData(trace.Data())
+			ctx = lagerctx.NewContextWithTraceInfo(ctx, trace)
+		}
+	}
+
+	return lagerctx.NewContext(ctx, logger), logger
+}
+
+// clientContext opens the session for a call and passes the trace from the
+// context on to the server in the outgoing metadata.
+func (i *interceptor) clientContext(ctx context.Context, fullMethod string) (context.Context, lager.Logger) {
+	logger := i.session(fullMethod)
+
+	trace, ok := lagerctx.TraceInfoFromContext(ctx)
+	if !ok {
+		return ctx, logger
+	}
+
+	logger = logger.WithData(trace.Data())
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if len(md.Get(lager.RequestIdHeader)) == 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, lager.RequestIdHeader, trace.RequestID())
+	}
+	if len(md.Get(lager.TraceparentHeader)) == 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, lager.TraceparentHeader, trace.Traceparent())
+		if trace.TraceState != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, lager.TracestateHeader, trace.TraceState)
+		}
+	}
+
+	return ctx, logger
+}
+
+func (i *interceptor) start(logger lager.Logger, fullMethod string) time.Time {
+	if !i.quiet(fullMethod) {
+		logger.Info(i.startMessage)
+	}
+	return time.Now()
+}
+
+func (i *interceptor) done(logger lager.Logger, fullMethod string, start time.Time, err error) {
+	if i.quiet(fullMethod) {
+		return
+	}
+
+	data := lager.Data{
+		"code":     status.Code(err).String(),
+		"duration": time.Since(start).String(),
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	logger.Info("done", data)
+}
+
+// NewUnaryServerInterceptor returns an interceptor that opens a session on
+// the logger for every unary call, with the trace from the incoming metadata
+// added as WithTraceInfo would, and stores it in the call context with
+// lagerctx.NewContext. The session logs "serving" when the call starts and
+// "done", with the status code and duration, when it finishes.
+func NewUnaryServerInterceptor(logger lager.Logger, config InterceptorConfig) grpc.UnaryServerInterceptor {
+	i := newInterceptor(logger, config, DefaultServerSessionName, "serving")
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, logger := i.serverContext(ctx, info.FullMethod)
+
+		start := i.start(logger, info.FullMethod)
+		resp, err := handler(ctx, req)
+		i.done(logger, info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+// NewStreamServerInterceptor returns the streaming counterpart of
+// NewUnaryServerInterceptor.
+func NewStreamServerInterceptor(logger lager.Logger, config InterceptorConfig) grpc.StreamServerInterceptor {
+	i := newInterceptor(logger, config, DefaultServerSessionName, "serving")
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, logger := i.serverContext(ss.Context(), info.FullMethod)
+
+		start := i.start(logger, info.FullMethod)
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		i.done(logger, info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+// NewUnaryClientInterceptor returns an interceptor that opens a session on
+// the logger for every unary call and passes the trace stored in the call
+// context by lagerctx.NewContextWithTraceInfo on to the server in the
+// x-vcap-request-id and traceparent metadata. The session logs "calling"
+// when the call starts and "done" like the server interceptors.
+func NewUnaryClientInterceptor(logger lager.Logger, config InterceptorConfig) grpc.UnaryClientInterceptor {
+	i := newInterceptor(logger, config, DefaultClientSessionName, "calling")
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, logger := i.clientContext(ctx, method)
+
+		start := i.start(logger, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		i.done(logger, method, start, err)
+
+		return err
+	}
+}
+
+// NewStreamClientInterceptor returns the streaming counterpart of
+// NewUnaryClientInterceptor. The call is logged as done once RecvMsg returns
+// an error, including io.EOF at the end of the stream, so callers that stop
+// reading early do not get a "done" log.
+func NewStreamClientInterceptor(logger lager.Logger, config InterceptorConfig) grpc.StreamClientInterceptor {
+	i := newInterceptor(logger, config, DefaultClientSessionName, "calling")
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, logger := i.clientContext(ctx, method)
+
+		start := i.start(logger, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			i.done(logger, method, start, err)
+			return nil, err
+		}
+
+		return &clientStream{
+			ClientStream: cs,
+			done: func(err error) {
+				i.done(logger, method, start, err)
+			},
+		}, nil
+	}
+}
+
+// serverStream replaces the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// clientStream calls done once when the stream ends.
+type clientStream struct {
+	grpc.ClientStream
+	done func(error)
+	once sync.Once
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		if err == io.EOF {
+			s.once.Do(func() { s.done(nil) })
+		} else {
+			s.once.Do(func() { s.done(err) })
+		}
+	}
+	return err
+}
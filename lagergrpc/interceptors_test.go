@@ -0,0 +1,223 @@
+package lagergrpc_test
+
+import (
+	"context"
+	"io"
+	"net"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagerctx"
+	"code.cloudfoundry.org/lager/v3/lagergrpc"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+)
+
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	traces chan lager.TraceInfo
+}
+
+func (s *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	lagerctx.FromContext(ctx).Info("checking")
+	if trace, ok := lagerctx.TraceInfoFromContext(ctx); ok {
+		s.traces <- trace
+	}
+
+	if req.Service == "unknown" {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	lagerctx.FromContext(stream.Context()).Info("watching")
+	for i := 0; i < 2; i++ {
+		err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ = Describe("Interceptors", func() {
+	var (
+		serverLogger *lagertest.TestLogger
+		clientLogger *lagertest.TestLogger
+		serverConfig lagergrpc.InterceptorConfig
+		clientConfig lagergrpc.InterceptorConfig
+		service      *healthServer
+
+		server *grpc.Server
+		conn   *grpc.ClientConn
+		client healthpb.HealthClient
+	)
+
+	BeforeEach(func() {
+		serverLogger = lagertest.NewTestLogger("server")
+		clientLogger = lagertest.NewTestLogger("client")
+		serverConfig = lagergrpc.InterceptorConfig{}
+		clientConfig = lagergrpc.InterceptorConfig{}
+		service = &healthServer{traces: make(chan lager.TraceInfo, 1)}
+	})
+
+	JustBeforeEach(func() {
+		listener := bufconn.Listen(1024 * 1024)
+
+		server = grpc.NewServer(
+			grpc.UnaryInterceptor(lagergrpc.NewUnaryServerInterceptor(serverLogger, serverConfig)),
+			grpc.StreamInterceptor(lagergrpc.NewStreamServerInterceptor(serverLogger, serverConfig)),
+		)
+		healthpb.RegisterHealthServer(server, service)
+		go server.Serve(listener) //nolint:errcheck
+
+		var err error
+		conn, err = grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return listener.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(lagergrpc.NewUnaryClientInterceptor(clientLogger, clientConfig)),
+			grpc.WithStreamInterceptor(lagergrpc.NewStreamClientInterceptor(clientLogger, clientConfig)),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		client = healthpb.NewHealthClient(conn)
+	})
+
+	AfterEach(func() {
+		conn.Close()
+		server.Stop()
+	})
+
+	Describe("unary calls", func() {
+		It("logs the call in a session on both sides", func() {
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(serverLogger.LogMessages).Should(Equal([]string{
+				"server.grpc-request.serving",
+				"server.grpc-request.checking",
+				"server.grpc-request.done",
+			}))
+			for _, log := range serverLogger.Logs() {
+				Expect(log.Data).To(HaveKeyWithValue("method", "/grpc.health.v1.Health/Check"))
+				Expect(log.Data).To(HaveKeyWithValue("session", "1"))
+			}
+			Expect(serverLogger.Logs()[2].Data).To(HaveKeyWithValue("code", "OK"))
+			Expect(serverLogger.Logs()[2].Data).To(HaveKey("duration"))
+
+			Expect(clientLogger.LogMessages()).To(Equal([]string{
+				"client.grpc-call.calling",
+				"client.grpc-call.done",
+			}))
+			Expect(clientLogger.Logs()[1].Data).To(HaveKeyWithValue("code", "OK"))
+			Expect(clientLogger.Logs()[1].Data).To(HaveKeyWithValue("method", "/grpc.health.v1.Health/Check"))
+		})
+
+		It("logs the status code of failed calls", func() {
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+
+			Eventually(serverLogger.LogMessages).Should(HaveLen(3))
+			Expect(serverLogger.Logs()[2].Data).To(HaveKeyWithValue("code", "NotFound"))
+			Expect(serverLogger.Logs()[2].Data).To(HaveKeyWithValue("error", ContainSubstring("unknown service")))
+			Expect(clientLogger.Logs()[1].Data).To(HaveKeyWithValue("code", "NotFound"))
+		})
+
+		It("passes the trace in the client context on to the server", func() {
+			sampled := true
+			trace := lager.TraceInfo{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "53995c3f42cd8ad8",
+				Sampled: &sampled,
+			}
+			ctx := lagerctx.NewContextWithTraceInfo(context.Background(), trace)
+
+			_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+			Expect(err).NotTo(HaveOccurred())
+
+			var serverTrace lager.TraceInfo
+			Eventually(service.traces).Should(Receive(&serverTrace))
+			Expect(serverTrace.TraceID).To(Equal(trace.TraceID))
+			Expect(serverTrace.ParentSpanID).To(Equal(trace.SpanID))
+			Expect(*serverTrace.Sampled).To(BeTrue())
+
+			Eventually(serverLogger.LogMessages).Should(HaveLen(3))
+			for _, log := range serverLogger.Logs() {
+				Expect(log.Data).To(HaveKeyWithValue("trace-id", trace.TraceID))
+				Expect(log.Data).To(HaveKeyWithValue("span-id", serverTrace.SpanID))
+			}
+			for _, log := range clientLogger.Logs() {
+				Expect(log.Data).To(HaveKeyWithValue("trace-id", trace.TraceID))
+				Expect(log.Data).To(HaveKeyWithValue("span-id", trace.SpanID))
+			}
+		})
+
+		Context("when the method is excluded", func() {
+			BeforeEach(func() {
+				serverConfig.ExcludeMethods = []string{"/grpc.health.v1.Health/Check"}
+				clientConfig.ExcludeMethods = []string{"/grpc.health.v1.Health/Check"}
+			})
+
+			It("does not log the start and end of the call", func() {
+				_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(serverLogger.LogMessages()).To(Equal([]string{"server.grpc-request.checking"}))
+				Expect(clientLogger.LogMessages()).To(BeEmpty())
+			})
+		})
+
+		Context("with a session name", func() {
+			BeforeEach(func() {
+				serverConfig.SessionName = func(fullMethod string) string {
+					return "health"
+				}
+			})
+
+			It("names the session with it", func() {
+				_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(serverLogger.LogMessages()).To(ContainElement("server.health.checking"))
+			})
+		})
+	})
+
+	Describe("streaming calls", func() {
+		It("logs the call in a session on both sides", func() {
+			stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+			Expect(err).NotTo(HaveOccurred())
+
+			for {
+				_, err = stream.Recv()
+				if err != nil {
+					break
+				}
+			}
+			Expect(err).To(Equal(io.EOF))
+
+			Eventually(serverLogger.LogMessages).Should(Equal([]string{
+				"server.grpc-request.serving",
+				"server.grpc-request.watching",
+				"server.grpc-request.done",
+			}))
+			Expect(serverLogger.Logs()[2].Data).To(HaveKeyWithValue("code", "OK"))
+			Expect(serverLogger.Logs()[2].Data).To(HaveKeyWithValue("method", "/grpc.health.v1.Health/Watch"))
+
+			Expect(clientLogger.LogMessages()).To(Equal([]string{
+				"client.grpc-call.calling",
+				"client.grpc-call.done",
+			}))
+			Expect(clientLogger.Logs()[1].Data).To(HaveKeyWithValue("code", "OK"))
+		})
+	})
+})
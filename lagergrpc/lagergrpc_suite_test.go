@@ -0,0 +1,13 @@
+package lagergrpc_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLagergrpc(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Lagergrpc Suite")
+}
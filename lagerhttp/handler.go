@@ -0,0 +1,194 @@
+// Package lagerhttp provides HTTP server middleware that gives each request
+// its own Lager session.
+package lagerhttp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagerctx"
+)
+
+// DefaultSessionName is the name of the session opened for each request when
+// HandlerConfig.SessionName is not set.
+const DefaultSessionName = "request"
+
+// HandlerConfig controls how NewHandler logs requests.
+type HandlerConfig struct {
+	// SessionName returns the name of the session opened for the request.
+	// Requests get a session called DefaultSessionName when it is nil.
+	SessionName func(*http.Request) string
+
+	// ExcludePaths are request paths, such as health checks, that are not
+	// logged when they start and finish. Their handlers still get a logger
+	// in the request context.
+	ExcludePaths []string
+}
+
+// NewHandler returns an http.Handler that opens a session on the logger for
+// every request, with the trace from the request headers added as
+// WithTraceInfo would, and stores it in the request context with
+// lagerctx.NewContext. The trace is stored too, with
+// lagerctx.NewContextWithTraceInfo, so that requests made with
+// lagerctx.NewTraceRoundTripper continue it.
+//
+// The session logs "serving" when the request starts and "done", with the
+// status, bytes written and duration, when it finishes. A panic in the
+// wrapped handler is logged as an error and answered with a 500 if nothing
+// has been written yet.
+func NewHandler(handler http.Handler, logger lager.Logger, config HandlerConfig) http.Handler {
+	excluded := make(map[string]struct{}, len(config.ExcludePaths))
+	for _, path := range config.ExcludePaths {
+		excluded[path] = struct{}{}
+	}
+
+	return &loggingHandler{
+		handler:  handler,
+		logger:   logger,
+		config:   config,
+		excluded: excluded,
+	}
+}
+
+type loggingHandler struct {
+	handler  http.Handler
+	logger   lager.Logger
+	config   HandlerConfig
+	excluded map[string]struct{}
+}
+
+func (h *loggingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := DefaultSessionName
+	if h.config.SessionName != nil {
+		name = h.config.SessionName(r)
+	}
+
+	logger := h.logger.Session(name, lager.Data{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+
+	ctx := r.Context()
+	if trace, ok := lager.NewTraceInfo(r); ok {
+		logger = logger.WithData(trace.Data())
+		ctx = lagerctx.NewContextWithTraceInfo(ctx, trace)
+	}
+	r = r.WithContext(lagerctx.NewContext(ctx, logger))
+
+	_, quiet := h.excluded[r.URL.Path]
+
+	rw := &responseWriter{ResponseWriter: w}
+	start := time.Now()
+
+	if !quiet {
+		logger.Info("serving", lager.Data{"remote-addr": r.RemoteAddr})
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			logger.Error("panicked", panicError(p))
+			if !rw.wroteHeader && !rw.hijacked {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}
+
+		if !quiet {
+			data := lager.Data{
+				"status":   rw.status(),
+				"bytes":    rw.bytes,
+				"duration": time.Since(start).String(),
+			}
+			if rw.hijacked {
+				data["hijacked"] = true
+			}
+			logger.Info("done", data)
+		}
+	}()
+
+	h.handler.ServeHTTP(rw, r)
+}
+
+func panicError(p interface{}) error {
+	if err, ok := p.(error); ok {
+		return err
+	}
+	return errors.New(fmt.Sprint(p))
+}
+
+// responseWriter records the status and number of bytes written, and whether
+// the handler took over the connection.
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	bytes       int64
+	hijacked    bool
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader && statusCode >= http.StatusOK {
+		w.statusCode = statusCode
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.statusCode = http.StatusOK
+		w.wroteHeader = true
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom is passed through for handlers that check for io.ReaderFrom, so
+// that the underlying writer can still copy efficiently.
+func (w *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.statusCode = http.StatusOK
+		w.wroteHeader = true
+	}
+	n, err := io.Copy(w.ResponseWriter, src)
+	w.bytes += n
+	return n, err
+}
+
+// Hijack is passed through for handlers that check for http.Hijacker, such as
+// websocket upgrades. It fails with http.ErrNotSupported when the underlying
+// writer cannot be hijacked.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Flush is passed through for handlers that check for http.Flusher.
+func (w *responseWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush() //nolint:errcheck
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) status() int {
+	if !w.wroteHeader {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
@@ -0,0 +1,228 @@
+package lagerhttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagerctx"
+	"code.cloudfoundry.org/lager/v3/lagerhttp"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+)
+
+var _ = Describe("Handler", func() {
+	var (
+		logger   *lagertest.TestLogger
+		inner    http.HandlerFunc
+		config   lagerhttp.HandlerConfig
+		recorder *httptest.ResponseRecorder
+		req      *http.Request
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		inner = func(w http.ResponseWriter, r *http.Request) {
+			lagerctx.FromContext(r.Context()).Info("handling")
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, "hello") //nolint:errcheck
+		}
+		config = lagerhttp.HandlerConfig{}
+		recorder = httptest.NewRecorder()
+		req = httptest.NewRequest("POST", "/things?secret=1", nil)
+	})
+
+	JustBeforeEach(func() {
+		lagerhttp.NewHandler(inner, logger, config).ServeHTTP(recorder, req)
+	})
+
+	It("logs the start and end of the request in a session", func() {
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		Expect(recorder.Body.String()).To(Equal("hello"))
+
+		Expect(logger.LogMessages()).To(Equal([]string{
+			"test.request.serving",
+			"test.request.handling",
+			"test.request.done",
+		}))
+
+		logs := logger.Logs()
+		for _, log := range logs {
+			Expect(log.Data).To(HaveKeyWithValue("session", "1"))
+			Expect(log.Data).To(HaveKeyWithValue("method", "POST"))
+			Expect(log.Data).To(HaveKeyWithValue("path", "/things"))
+		}
+
+		Expect(logs[0].Data).To(HaveKeyWithValue("remote-addr", req.RemoteAddr))
+		Expect(logs[2].Data).To(HaveKeyWithValue("status", BeNumerically("==", http.StatusCreated)))
+		Expect(logs[2].Data).To(HaveKeyWithValue("bytes", BeNumerically("==", 5)))
+		Expect(logs[2].Data).To(HaveKey("duration"))
+	})
+
+	Context("when the handler does not write a status", func() {
+		BeforeEach(func() {
+			inner = func(w http.ResponseWriter, r *http.Request) {}
+		})
+
+		It("logs a 200", func() {
+			Expect(logger.Logs()[1].Data).To(HaveKeyWithValue("status", BeNumerically("==", http.StatusOK)))
+			Expect(logger.Logs()[1].Data).To(HaveKeyWithValue("bytes", BeNumerically("==", 0)))
+		})
+	})
+
+	Context("when the handler flushes", func() {
+		BeforeEach(func() {
+			inner = func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "partial") //nolint:errcheck
+				w.(http.Flusher).Flush()
+			}
+		})
+
+		It("flushes the underlying writer", func() {
+			Expect(recorder.Flushed).To(BeTrue())
+		})
+	})
+
+	Context("when the handler copies with io.ReaderFrom", func() {
+		BeforeEach(func() {
+			inner = func(w http.ResponseWriter, r *http.Request) {
+				w.(io.ReaderFrom).ReadFrom(strings.NewReader("copied")) //nolint:errcheck
+			}
+		})
+
+		It("writes to the underlying writer and counts the bytes", func() {
+			Expect(recorder.Body.String()).To(Equal("copied"))
+			Expect(logger.Logs()[1].Data).To(HaveKeyWithValue("bytes", BeNumerically("==", 6)))
+		})
+	})
+
+	Context("when the handler hijacks the connection", func() {
+		BeforeEach(func() {
+			inner = func(w http.ResponseWriter, r *http.Request) {
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				defer conn.Close()
+
+				response := "HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked"
+				buf.WriteString(response) //nolint:errcheck
+				buf.Flush()               //nolint:errcheck
+			}
+		})
+
+		It("fails when the underlying writer cannot be hijacked", func() {
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			Expect(recorder.Body.String()).To(ContainSubstring(http.ErrNotSupported.Error()))
+		})
+
+		It("passes the connection through and logs that it was hijacked", func() {
+			logger = lagertest.NewTestLogger("test")
+			server := httptest.NewServer(lagerhttp.NewHandler(inner, logger, config))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal("hijacked"))
+
+			Eventually(logger.LogMessages).Should(ContainElement("test.request.done"))
+			Expect(logger.Logs()[1].Data).To(HaveKeyWithValue("hijacked", true))
+		})
+	})
+
+	Context("when the request carries a trace", func() {
+		var trace lager.TraceInfo
+
+		BeforeEach(func() {
+			req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			inner = func(w http.ResponseWriter, r *http.Request) {
+				trace, _ = lagerctx.TraceInfoFromContext(r.Context())
+			}
+		})
+
+		It("adds it to the session and the context", func() {
+			Expect(trace.TraceID).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			Expect(trace.ParentSpanID).To(Equal("00f067aa0ba902b7"))
+
+			for _, log := range logger.Logs() {
+				Expect(log.Data).To(HaveKeyWithValue("trace-id", trace.TraceID))
+				Expect(log.Data).To(HaveKeyWithValue("span-id", trace.SpanID))
+			}
+		})
+	})
+
+	Context("with a session name", func() {
+		BeforeEach(func() {
+			config.SessionName = func(r *http.Request) string {
+				return "api-" + r.Method
+			}
+		})
+
+		It("names the session with it", func() {
+			Expect(logger.LogMessages()).To(ContainElement("test.api-POST.serving"))
+		})
+	})
+
+	Context("when the path is excluded", func() {
+		BeforeEach(func() {
+			config.ExcludePaths = []string{"/health"}
+			req = httptest.NewRequest("GET", "/health", nil)
+		})
+
+		It("does not log the start and end of the request", func() {
+			Expect(logger.LogMessages()).To(Equal([]string{"test.request.handling"}))
+		})
+	})
+
+	Context("when the handler panics", func() {
+		BeforeEach(func() {
+			inner = func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			}
+		})
+
+		It("logs an error and responds with a 500", func() {
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+
+			logs := logger.Logs()
+			Expect(logs).To(HaveLen(3))
+			Expect(logs[1].Message).To(Equal("test.request.panicked"))
+			Expect(logs[1].LogLevel).To(Equal(lager.ERROR))
+			Expect(logs[1].Data).To(HaveKeyWithValue("error", "boom"))
+			Expect(logs[2].Data).To(HaveKeyWithValue("status", BeNumerically("==", http.StatusInternalServerError)))
+		})
+
+		Context("after writing the status", func() {
+			BeforeEach(func() {
+				inner = func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusAccepted)
+					panic("boom")
+				}
+			})
+
+			It("leaves the response alone", func() {
+				Expect(recorder.Code).To(Equal(http.StatusAccepted))
+				Expect(logger.Logs()[2].Data).To(HaveKeyWithValue("status", BeNumerically("==", http.StatusAccepted)))
+			})
+		})
+	})
+
+	It("re-panics when the handler aborts so that net/http can abort the connection", func() {
+		handler := lagerhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}), logger, config)
+
+		Expect(func() {
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}).To(PanicWith(http.ErrAbortHandler))
+	})
+})
@@ -0,0 +1,13 @@
+package lagerhttp_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLagerhttp(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Lagerhttp Suite")
+}
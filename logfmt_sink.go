@@ -0,0 +1,206 @@
+package lager
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+type logfmtSink struct {
+	writer      io.Writer
+	minLogLevel LogLevel
+	pretty      bool
+	writeL      sync.Mutex
+}
+
+// NewLogfmtSink returns a sink that writes logs in logfmt, e.g.
+//
+//	timestamp=1709296496.789000000 source=my-app message=my-app.did-something log_level=1 session=1 request.path=/v1/things
+//
+// with the same fields and timestamps as NewWriterSink. Nested data is
+// flattened into dotted keys, with slices indexed from 0.
+func NewLogfmtSink(writer io.Writer, minLogLevel LogLevel) Sink {
+	return &logfmtSink{
+		writer:      writer,
+		minLogLevel: minLogLevel,
+	}
+}
+
+// NewPrettyLogfmtSink is like NewLogfmtSink but has the fields and RFC3339
+// timestamps of NewPrettySink, e.g.
+//
+//	timestamp=2024-03-01T12:34:56.789000000Z level=info source=my-app message=my-app.did-something
+func NewPrettyLogfmtSink(writer io.Writer, minLogLevel LogLevel) Sink {
+	return &logfmtSink{
+		writer:      writer,
+		minLogLevel: minLogLevel,
+		pretty:      true,
+	}
+}
+
+func (sink *logfmtSink) Log(log LogFormat) {
+	if log.LogLevel < sink.minLogLevel {
+		return
+	}
+
+	// Format outside of critical section to minimize time spent holding lock
+	buf := getBuffer()
+	*buf = append(log.appendLogfmt(*buf, sink.pretty), '\n')
+
+	sink.writeL.Lock()
+	sink.writer.Write(*buf) //nolint:errcheck
+	sink.writeL.Unlock()
+
+	putBuffer(buf)
+}
+
+func (sink *logfmtSink) Enabled(level LogLevel) bool {
+	return level >= sink.minLogLevel
+}
+
+func (log LogFormat) appendLogfmt(dst []byte, pretty bool) []byte {
+	dst = append(dst, "timestamp="...)
+	if pretty {
+		t := log.time
+		if t.IsZero() {
+			t = parseTimestamp(log.Timestamp)
+		}
+		dst = t.UTC().AppendFormat(dst, rfc3339Nano)
+		dst = append(dst, " level="...)
+		dst = append(dst, log.LogLevel.String()...)
+	} else {
+		dst = appendLogfmtString(dst, log.Timestamp)
+	}
+
+	dst = append(dst, " source="...)
+	dst = appendLogfmtString(dst, log.Source)
+	dst = append(dst, " message="...)
+	dst = appendLogfmtString(dst, log.Message)
+
+	if !pretty {
+		dst = append(dst, " log_level="...)
+		dst = log.LogLevel.appendNumber(dst)
+	}
+
+	flattenData("", log.Data, func(key, value string, isString bool) {
+		dst = appendLogfmtKey(dst, key)
+		switch {
+		case !isString:
+			dst = append(dst, value...)
+		case logfmtLooksLikeJSON(value):
+			dst = strconv.AppendQuote(dst, value)
+		default:
+			dst = appendLogfmtString(dst, value)
+		}
+	})
+	return dst
+}
+
+// flattenData calls leaf for each value in data, in key order, with nested
+// maps and slices flattened into dotted keys and slices indexed from 0. Values
+// other than strings are passed as JSON, and nil as an empty value.
+func flattenData(prefix string, data map[string]interface{}, leaf func(key, value string, isString bool)) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+
+	for _, k := range keys {
+		flattenValue(prefix+k, data[k], leaf)
+	}
+}
+
+func flattenValue(key string, v interface{}, leaf func(key, value string, isString bool)) {
+	switch v := v.(type) {
+	case Data:
+		flattenData(key+".", v, leaf)
+		return
+	case map[string]interface{}:
+		flattenData(key+".", v, leaf)
+		return
+	case []interface{}:
+		for i, e := range v {
+			flattenValue(key+"."+strconv.Itoa(i), e, leaf)
+		}
+		return
+	case string:
+		leaf(key, v, true)
+		return
+	case nil:
+		leaf(key, "", false)
+		return
+	}
+
+	encoded, err := appendJSONValue(nil, v)
+	if err != nil {
+		leaf(key, err.Error(), true)
+		return
+	}
+
+	switch encoded[0] {
+	case '{', '[':
+		// flatten structs and other types the same way as the JSON they
+		// would be written as
+		var decoded interface{}
+		if json.Unmarshal(encoded, &decoded) == nil {
+			flattenValue(key, decoded, leaf)
+			return
+		}
+	case '"':
+		var s string
+		if json.Unmarshal(encoded, &s) == nil {
+			leaf(key, s, true)
+			return
+		}
+	}
+
+	leaf(key, string(encoded), false)
+}
+
+// appendLogfmtKey writes the key, replacing characters that would end it
+// early with underscores.
+func appendLogfmtKey(dst []byte, key string) []byte {
+	dst = append(dst, ' ')
+	if key == "" {
+		return append(dst, "_="...)
+	}
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			dst = append(dst, '_')
+		} else {
+			dst = utf8.AppendRune(dst, r)
+		}
+	}
+	return append(dst, '=')
+}
+
+// appendLogfmtString quotes the value when it is empty or contains spaces,
+// equals signs, quotes or control characters.
+func appendLogfmtString(dst []byte, s string) []byte {
+	if s != "" && !logfmtNeedsQuoting(s) {
+		return append(dst, s...)
+	}
+	return strconv.AppendQuote(dst, s)
+}
+
+func logfmtNeedsQuoting(s string) bool {
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// logfmtLooksLikeJSON reports whether a string would be read back as a
+// number or boolean if it were not quoted.
+func logfmtLooksLikeJSON(s string) bool {
+	if s == "true" || s == "false" {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
@@ -0,0 +1,122 @@
+package lager_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("LogfmtSink", func() {
+	var (
+		buffer *gbytes.Buffer
+		sink   lager.Sink
+		logger lager.Logger
+	)
+
+	BeforeEach(func() {
+		buffer = gbytes.NewBuffer()
+		sink = lager.NewLogfmtSink(buffer, lager.INFO)
+		logger = lager.NewLogger("my-app")
+		logger.RegisterSink(sink)
+	})
+
+	It("writes the same fields as the writer sink", func() {
+		sink.Log(lager.LogFormat{
+			Timestamp: "1709296496.789000000",
+			Source:    "my-app",
+			Message:   "my-app.did-something",
+			LogLevel:  lager.INFO,
+			Data:      lager.Data{"count": 3},
+		})
+
+		Expect(string(buffer.Contents())).To(Equal(
+			"timestamp=1709296496.789000000 source=my-app message=my-app.did-something log_level=1 count=3\n",
+		))
+	})
+
+	It("flattens nested data into dotted keys", func() {
+		logger.Info("did-something", lager.Data{
+			"request": map[string]interface{}{
+				"path":    "/v1/things",
+				"headers": lager.Data{"accept": "*/*"},
+			},
+			"ids":   []interface{}{1, "two"},
+			"ok":    true,
+			"ratio": 0.5,
+		})
+
+		Expect(buffer).To(gbytes.Say(
+			`log_level=1 ids\.0=1 ids\.1=two ok=true ratio=0\.5 request\.headers\.accept=\*/\* request\.path=/v1/things\n`,
+		))
+	})
+
+	It("flattens other types the way they would be written as JSON", func() {
+		logger.Info("did-something", lager.Data{
+			"struct":   struct{ Name string }{Name: "foo"},
+			"duration": time.Second,
+			"time":     time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		})
+
+		Expect(buffer).To(gbytes.Say(`duration=1000000000 struct\.Name=foo time=2024-03-01T00:00:00Z\n`))
+	})
+
+	It("quotes and escapes values that need it", func() {
+		logger.Info("did-something", lager.Data{
+			"empty":   "",
+			"spaces":  "hello world",
+			"equals":  "a=b",
+			"quotes":  `say "hi"`,
+			"newline": "a\nb",
+			"number":  "123",
+			"bool":    "true",
+			"nil":     nil,
+		})
+
+		Expect(buffer).To(gbytes.Say(
+			`bool="true" empty="" equals="a=b" newline="a\\nb" nil= number="123" quotes="say \\"hi\\"" spaces="hello world"\n`,
+		))
+	})
+
+	It("replaces characters in keys that would break the line", func() {
+		logger.Info("did-something", lager.Data{"a key=": "v"})
+
+		Expect(buffer).To(gbytes.Say(` a_key_=v\n`))
+	})
+
+	It("records errors", func() {
+		logger.Error("failed", errors.New("it broke"))
+
+		Expect(buffer).To(gbytes.Say(`message=my-app\.failed log_level=2 error="it broke"\n`))
+	})
+
+	It("does not write logs below the minimum level", func() {
+		logger.Debug("hidden")
+		Expect(buffer.Contents()).To(BeEmpty())
+		Expect(lager.SinkEnabled(sink, lager.DEBUG)).To(BeFalse())
+	})
+
+	Describe("NewPrettyLogfmtSink", func() {
+		BeforeEach(func() {
+			sink = lager.NewPrettyLogfmtSink(buffer, lager.INFO)
+		})
+
+		It("writes the same fields as the pretty sink", func() {
+			sink.Log(lager.LogFormat{
+				Timestamp: "1709296496.789000000",
+				Source:    "my-app",
+				Message:   "my-app.did-something",
+				LogLevel:  lager.WARN,
+				Data:      lager.Data{"count": 3},
+			})
+
+			Expect(string(buffer.Contents())).To(Equal(
+				"timestamp=2024-03-01T12:34:56.789000000Z level=warn source=my-app message=my-app.did-something count=3\n",
+			))
+		})
+	})
+})
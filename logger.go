@@ -1,10 +1,11 @@
 package lager
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+	"os"
 	"runtime"
-	"strings"
+	"strconv"
 	"sync/atomic"
 	"time"
 
@@ -15,33 +16,80 @@ import (
 const (
 	StackTraceBufferSize = 1024 * 100
 	RequestIdHeader      = "X-Vcap-Request-Id"
+
+	maxStackTraceBufferSize = 64 * 1024 * 1024
 )
 
 type Logger interface {
 	RegisterSink(Sink)
 	Session(task string, data ...Data) Logger
 	SessionName() string
+	Enabled(level LogLevel) bool
 	Debug(action string, data ...Data)
 	Info(action string, data ...Data)
+	Warn(action string, data ...Data)
 	Error(action string, err error, data ...Data)
 	Fatal(action string, err error, data ...Data)
 	WithData(Data) Logger
 	WithTraceInfo(*http.Request) Logger
+	Flush() error
+}
+
+// LoggerConfig controls optional behaviour of a logger and every session
+// created from it.
+type LoggerConfig struct {
+	// ErrorDetails makes Error and Fatal record the error as an ErrorDetails
+	// object, with its type, the errors it wraps and any stack trace it
+	// carries, instead of only its message.
+	ErrorDetails bool
+
+	// FatalHandler is called by Fatal once the log has been written and the
+	// sinks flushed. It defaults to PanicOnFatal. If it returns, so does
+	// Fatal.
+	FatalHandler FatalHandler
+
+	// AllGoroutineStacks makes Fatal record the stacks of every goroutine
+	// rather than only the one that called it.
+	AllGoroutineStacks bool
+}
+
+// FatalHandler decides what happens to the process after Fatal has logged
+// err.
+type FatalHandler func(err error)
+
+// PanicOnFatal panics with the error, which is what Fatal does by default.
+func PanicOnFatal(err error) {
+	panic(err)
+}
+
+// ExitOnFatal returns a FatalHandler that exits the process with the code.
+func ExitOnFatal(code int) FatalHandler {
+	return func(error) {
+		os.Exit(code)
+	}
 }
 
 type logger struct {
 	component   string
+	config      LoggerConfig
 	task        string
 	sinks       []Sink
 	sessionID   string
 	nextSession uint32
+	// data already includes the session ID and is shared by every log that
+	// does not add data of its own, so it must never be modified
 	data        Data
 	idGenerator idgenerator.IDGenerator
 }
 
 func NewLogger(component string) Logger {
+	return NewLoggerWithConfig(component, LoggerConfig{})
+}
+
+func NewLoggerWithConfig(component string, config LoggerConfig) Logger {
 	return &logger{
 		component:   component,
+		config:      config,
 		task:        component,
 		sinks:       []Sink{},
 		data:        Data{},
@@ -63,17 +111,18 @@ func (l *logger) Session(task string, data ...Data) Logger {
 	var sessionIDstr string
 
 	if l.sessionID != "" {
-		sessionIDstr = fmt.Sprintf("%s.%d", l.sessionID, sid)
+		sessionIDstr = l.sessionID + "." + strconv.FormatUint(uint64(sid), 10)
 	} else {
-		sessionIDstr = fmt.Sprintf("%d", sid)
+		sessionIDstr = strconv.FormatUint(uint64(sid), 10)
 	}
 
 	return &logger{
 		component:   l.component,
-		task:        fmt.Sprintf("%s.%s", l.task, task),
+		config:      l.config,
+		task:        l.task + "." + task,
 		sinks:       l.sinks,
 		sessionID:   sessionIDstr,
-		data:        l.baseData(data...),
+		data:        l.mergeData(sessionIDstr, data...),
 		idGenerator: l.idGenerator,
 	}
 }
@@ -81,137 +130,189 @@ func (l *logger) Session(task string, data ...Data) Logger {
 func (l *logger) WithData(data Data) Logger {
 	return &logger{
 		component:   l.component,
+		config:      l.config,
 		task:        l.task,
 		sinks:       l.sinks,
 		sessionID:   l.sessionID,
-		data:        l.baseData(data),
+		data:        l.mergeData(l.sessionID, data),
 		idGenerator: l.idGenerator,
 	}
 }
 
+// WithTraceInfo returns a logger with the trace of the request, taken from
+// the W3C traceparent and tracestate headers, the B3 headers or the
+// X-Vcap-Request-Id header, in that order. The logger gets a new span, with
+// the caller's span and sampling decision recorded alongside it when the
+// headers carry them.
 func (l *logger) WithTraceInfo(req *http.Request) Logger {
-	traceIDHeader := req.Header.Get(RequestIdHeader)
-	if traceIDHeader == "" {
-		return l.WithData(nil)
-	}
-	traceHex := strings.Replace(traceIDHeader, "-", "", -1)
-	traceID, err := model.TraceIDFromHex(traceHex)
-	if err != nil {
+	tc, ok := traceContextFromRequest(req)
+	if !ok {
 		return l.WithData(nil)
 	}
 
 	spanID := l.idGenerator.SpanID(model.TraceID{})
-	return l.WithData(Data{"trace-id": traceID.String(), "span-id": spanID.String()})
+	return l.WithData(tc.traceInfo(spanID).Data())
 }
 
 func (l *logger) Debug(action string, data ...Data) {
-	t := time.Now().UTC()
-	log := LogFormat{
-		time:      t,
-		Timestamp: formatTimestamp(t),
-		Source:    l.component,
-		Message:   fmt.Sprintf("%s.%s", l.task, action),
-		LogLevel:  DEBUG,
-		Data:      l.baseData(data...),
+	if !l.Enabled(DEBUG) {
+		return
 	}
 
-	for _, sink := range l.sinks {
-		sink.Log(log)
-	}
+	l.log(l.newLogFormat(DEBUG, action, l.baseData(data...), nil))
 }
 
 func (l *logger) Info(action string, data ...Data) {
-	t := time.Now().UTC()
-	log := LogFormat{
-		time:      t,
-		Timestamp: formatTimestamp(t),
-		Source:    l.component,
-		Message:   fmt.Sprintf("%s.%s", l.task, action),
-		LogLevel:  INFO,
-		Data:      l.baseData(data...),
+	if !l.Enabled(INFO) {
+		return
 	}
 
-	for _, sink := range l.sinks {
-		sink.Log(log)
+	l.log(l.newLogFormat(INFO, action, l.baseData(data...), nil))
+}
+
+func (l *logger) Warn(action string, data ...Data) {
+	if !l.Enabled(WARN) {
+		return
 	}
+
+	l.log(l.newLogFormat(WARN, action, l.baseData(data...), nil))
 }
 
 func (l *logger) Error(action string, err error, data ...Data) {
-	logData := l.baseData(data...)
-
-	if err != nil {
-		logData["error"] = err.Error()
+	if !l.Enabled(ERROR) {
+		return
 	}
 
-	t := time.Now().UTC()
-	log := LogFormat{
-		time:      t,
-		Timestamp: formatTimestamp(t),
-		Source:    l.component,
-		Message:   fmt.Sprintf("%s.%s", l.task, action),
-		LogLevel:  ERROR,
-		Data:      logData,
-		Error:     err,
-	}
+	logData := l.mergeData(l.sessionID, data...)
 
-	for _, sink := range l.sinks {
-		sink.Log(log)
+	if err != nil {
+		logData["error"] = l.errorData(err)
 	}
+
+	l.log(l.newLogFormat(ERROR, action, logData, err))
 }
 
 func (l *logger) Fatal(action string, err error, data ...Data) {
-	logData := l.baseData(data...)
-
-	stackTrace := make([]byte, StackTraceBufferSize)
-	stackSize := runtime.Stack(stackTrace, false)
-	stackTrace = stackTrace[:stackSize]
+	logData := l.mergeData(l.sessionID, data...)
 
 	if err != nil {
-		logData["error"] = err.Error()
+		logData["error"] = l.errorData(err)
+	}
+
+	logData["trace"] = stackTrace(l.config.AllGoroutineStacks)
+
+	l.log(l.newLogFormat(FATAL, action, logData, err))
+	l.Flush() //nolint:errcheck
+
+	if l.config.FatalHandler != nil {
+		l.config.FatalHandler(err)
+		return
 	}
 
-	logData["trace"] = string(stackTrace)
+	PanicOnFatal(err)
+}
+
+func (l *logger) errorData(err error) interface{} {
+	if l.config.ErrorDetails {
+		return NewErrorDetails(err)
+	}
+	return err.Error()
+}
+
+// Enabled reports whether any registered sink might write a log at the level,
+// so that logs nobody would write are not built in the first place.
+func (l *logger) Enabled(level LogLevel) bool {
+	for _, sink := range l.sinks {
+		if SinkEnabled(sink, level) {
+			return true
+		}
+	}
+	return false
+}
 
+func (l *logger) newLogFormat(level LogLevel, action string, data Data, err error) LogFormat {
 	t := time.Now().UTC()
-	log := LogFormat{
+	return LogFormat{
 		time:      t,
 		Timestamp: formatTimestamp(t),
 		Source:    l.component,
-		Message:   fmt.Sprintf("%s.%s", l.task, action),
-		LogLevel:  FATAL,
-		Data:      logData,
+		Message:   l.task + "." + action,
+		LogLevel:  level,
+		Data:      data,
 		Error:     err,
 	}
+}
 
+func (l *logger) log(log LogFormat) {
 	for _, sink := range l.sinks {
 		sink.Log(log)
 	}
-
-	panic(err)
 }
 
+// baseData returns the logger's data combined with the given data. When there
+// is nothing to add the logger's own data is returned without copying, so the
+// result must not be modified.
 func (l *logger) baseData(givenData ...Data) Data {
-	data := Data{}
+	for _, dataArg := range givenData {
+		if len(dataArg) > 0 {
+			return l.mergeData(l.sessionID, givenData...)
+		}
+	}
+
+	return l.data
+}
+
+// mergeData returns a new copy of the logger's data combined with the given
+// data and the session ID.
+func (l *logger) mergeData(sessionID string, givenData ...Data) Data {
+	size := len(l.data) + 1
+	for _, dataArg := range givenData {
+		size += len(dataArg)
+	}
+	data := make(Data, size)
 
 	for k, v := range l.data {
 		data[k] = v
 	}
 
-	if len(givenData) > 0 {
-		for _, dataArg := range givenData {
-			for key, val := range dataArg {
-				data[key] = val
-			}
+	for _, dataArg := range givenData {
+		for key, val := range dataArg {
+			data[key] = val
 		}
 	}
 
-	if l.sessionID != "" {
-		data["session"] = l.sessionID
+	if sessionID != "" {
+		data["session"] = sessionID
 	}
 
 	return data
 }
 
+// Flush flushes every registered sink that implements Flusher, so that
+// nothing is lost when the process exits. Fatal calls it before its handler.
+func (l *logger) Flush() error {
+	var errs []error
+	for _, sink := range l.sinks {
+		errs = append(errs, FlushSink(sink))
+	}
+	return errors.Join(errs...)
+}
+
+// stackTrace returns the stack of the calling goroutine, or of every
+// goroutine when all is set, growing the buffer from StackTraceBufferSize
+// until the trace fits.
+func stackTrace(all bool) string {
+	buf := make([]byte, StackTraceBufferSize)
+	for {
+		n := runtime.Stack(buf, all)
+		if n < len(buf) || len(buf) >= maxStackTraceBufferSize {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
 func formatTimestamp(t time.Time) string {
-	return fmt.Sprintf("%.9f", float64(t.UnixNano())/1e9)
+	var buf [32]byte
+	return string(strconv.AppendFloat(buf[:0], float64(t.UnixNano())/1e9, 'f', 9, 64))
 }
@@ -0,0 +1,70 @@
+package lager_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+func newBenchmarkLogger(minLogLevel lager.LogLevel) lager.Logger {
+	logger := lager.NewLogger("benchmark")
+	logger.RegisterSink(lager.NewWriterSink(io.Discard, minLogLevel))
+	return logger.Session("session", lager.Data{"request-id": "abc123", "attempt": 1})
+}
+
+func BenchmarkLoggerInfo(b *testing.B) {
+	logger := newBenchmarkLogger(lager.INFO)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		logger.Info("did-something")
+	}
+}
+
+func BenchmarkLoggerInfoWithData(b *testing.B) {
+	logger := newBenchmarkLogger(lager.INFO)
+	data := lager.Data{"path": "/v1/things", "status": 200, "duration": 0.25}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		logger.Info("did-something", data)
+	}
+}
+
+func BenchmarkLoggerError(b *testing.B) {
+	logger := newBenchmarkLogger(lager.INFO)
+	err := errors.New("boom")
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		logger.Error("failed", err)
+	}
+}
+
+func BenchmarkLoggerDebugDisabled(b *testing.B) {
+	logger := newBenchmarkLogger(lager.INFO)
+	data := lager.Data{"path": "/v1/things"}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		logger.Debug("did-something", data)
+	}
+}
+
+func BenchmarkReconfigurableSinkDebugDisabled(b *testing.B) {
+	logger := lager.NewLogger("benchmark")
+	logger.RegisterSink(lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO))
+	data := lager.Data{"path": "/v1/things"}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		logger.Debug("did-something", data)
+	}
+}
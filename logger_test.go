@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/lager/v3"
@@ -240,6 +241,25 @@ var _ = Describe("Logger", func() {
 		})
 	})
 
+	Describe("Warn", func() {
+		Context("with log data", func() {
+			BeforeEach(func() {
+				logger.Warn(action, logData, anotherLogData)
+			})
+
+			TestCommonLogFeatures(lager.WARN)
+			TestLogData()
+		})
+
+		Context("with no log data", func() {
+			BeforeEach(func() {
+				logger.Warn(action)
+			})
+
+			TestCommonLogFeatures(lager.WARN)
+		})
+	})
+
 	Describe("Error", func() {
 		var err = errors.New("oh noes!")
 		Context("with log data", func() {
@@ -373,6 +393,197 @@ var _ = Describe("Logger", func() {
 			})
 		})
 
+		Context("with a FatalHandler configured", func() {
+			var handledErr error
+
+			BeforeEach(func() {
+				handledErr = nil
+				logger = lager.NewLoggerWithConfig(component, lager.LoggerConfig{
+					FatalHandler: func(err error) {
+						handledErr = err
+					},
+				})
+				logger.RegisterSink(testSink)
+			})
+
+			It("calls the handler instead of panicking", func() {
+				Expect(func() {
+					logger.Session("sub-action").Fatal(action, err)
+				}).NotTo(Panic())
+
+				Expect(handledErr).To(Equal(err))
+				Expect(testSink.LogMessages()).To(Equal([]string{"my-component.sub-action.my-action"}))
+			})
+
+			It("flushes sinks before calling the handler", func() {
+				asyncSink := lager.NewAsyncSink(testSink, 10, lager.OverflowBlock, lager.DEBUG)
+				defer asyncSink.Close() //nolint:errcheck
+
+				logger = lager.NewLoggerWithConfig(component, lager.LoggerConfig{
+					FatalHandler: func(error) {
+						Expect(testSink.LogMessages()).To(Equal([]string{
+							"my-component.before-fatal",
+							"my-component.my-action",
+						}))
+					},
+				})
+				logger.RegisterSink(asyncSink)
+
+				logger.Info("before-fatal")
+				logger.Fatal(action, err)
+			})
+		})
+
+		Context("with AllGoroutineStacks configured", func() {
+			It("records the stack of every goroutine", func() {
+				block := make(chan struct{})
+				defer close(block)
+				go func() {
+					<-block
+				}()
+
+				logger = lager.NewLoggerWithConfig(component, lager.LoggerConfig{
+					AllGoroutineStacks: true,
+					FatalHandler:       func(error) {},
+				})
+				logger.RegisterSink(testSink)
+				logger.Fatal(action, err)
+
+				trace, ok := testSink.Logs()[0].Data["trace"].(string)
+				Expect(ok).To(BeTrue())
+				Expect(strings.Count(trace, "goroutine ")).To(BeNumerically(">", 1))
+			})
+		})
+	})
+
+	Describe("Flush", func() {
+		It("flushes every registered sink that buffers logs", func() {
+			flushed := &lifecycleSink{}
+			failing := &lifecycleSink{err: errors.New("disk full")}
+			logger.RegisterSink(flushed)
+			logger.RegisterSink(failing)
+
+			Expect(logger.Session("sub-action").Flush()).To(MatchError("disk full"))
+			Expect(flushed.flushes).To(Equal(1))
+			Expect(failing.flushes).To(Equal(1))
+		})
+	})
+
+	Describe("with ErrorDetails configured", func() {
+		var err error
+
+		BeforeEach(func() {
+			logger = lager.NewLoggerWithConfig(component, lager.LoggerConfig{ErrorDetails: true})
+			logger.RegisterSink(testSink)
+			err = fmt.Errorf("wrapped: %w", errors.New("oh no!"))
+		})
+
+		It("records the error as an object in Error", func() {
+			logger.Session("sub-action").Error(action, err)
+
+			Expect(testSink.Logs()[0].Data["error"]).To(Equal(map[string]interface{}{
+				"message": "wrapped: oh no!",
+				"type":    "*fmt.wrapError",
+				"causes": []interface{}{
+					map[string]interface{}{
+						"message": "oh no!",
+						"type":    "*errors.errorString",
+					},
+				},
+			}))
+		})
+
+		It("records the error as an object in Fatal", func() {
+			func() {
+				defer func() {
+					recover() //nolint:errcheck
+				}()
+				logger.Fatal(action, err)
+			}()
+
+			Expect(testSink.Logs()[0].Data["error"]).To(HaveKeyWithValue("message", "wrapped: oh no!"))
+			Expect(testSink.Logs()[0].Data["trace"]).NotTo(BeEmpty())
+		})
+
+		It("is kept by sessions", func() {
+			logger.Session("sub-action").WithData(lager.Data{"foo": "bar"}).Error(action, err)
+
+			Expect(testSink.Logs()[0].Data["error"]).To(HaveKeyWithValue("type", "*fmt.wrapError"))
+		})
+	})
+
+	Describe("Enabled", func() {
+		var reconfigurableSink *lager.ReconfigurableSink
+
+		BeforeEach(func() {
+			logger = lager.NewLogger(component)
+			reconfigurableSink = lager.NewReconfigurableSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG), lager.INFO)
+			logger.RegisterSink(reconfigurableSink)
+		})
+
+		It("reports whether a registered sink would write the level", func() {
+			Expect(logger.Enabled(lager.DEBUG)).To(BeFalse())
+			Expect(logger.Enabled(lager.INFO)).To(BeTrue())
+			Expect(logger.Enabled(lager.ERROR)).To(BeTrue())
+		})
+
+		It("follows changes to the sink's level", func() {
+			reconfigurableSink.SetMinLevel(lager.DEBUG)
+			Expect(logger.Enabled(lager.DEBUG)).To(BeTrue())
+		})
+
+		It("takes level overrides into account", func() {
+			reconfigurableSink.SetOverride(lager.LevelOverride{Session: "my-component.task", MinLevel: lager.DEBUG})
+			Expect(logger.Enabled(lager.DEBUG)).To(BeTrue())
+		})
+
+		It("asks sinks wrapped by other sinks", func() {
+			logger = lager.NewLogger(component)
+			truncatingSink := lager.NewTruncatingSink(lager.NewWriterSink(GinkgoWriter, lager.ERROR), 20)
+			redactingSink, err := lager.NewRedactingSink(truncatingSink, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			logger.RegisterSink(redactingSink)
+
+			Expect(logger.Enabled(lager.INFO)).To(BeFalse())
+			Expect(logger.Enabled(lager.ERROR)).To(BeTrue())
+		})
+
+		It("treats sinks that do not report a level as enabled", func() {
+			logger.RegisterSink(lagertest.NewTestSink())
+			Expect(logger.Enabled(lager.DEBUG)).To(BeTrue())
+		})
+
+		It("is false when there are no sinks", func() {
+			Expect(lager.NewLogger(component).Enabled(lager.FATAL)).To(BeFalse())
+		})
+	})
+
+	Describe("session data", func() {
+		var session lager.Logger
+
+		BeforeEach(func() {
+			session = logger.Session("sub-action", lager.Data{"foo": "bar"})
+		})
+
+		It("is not modified by errors", func() {
+			session.Error("failed", errors.New("oh no!"))
+			session.Info("succeeded")
+
+			Expect(testSink.Logs()[0].Data).To(HaveKey("error"))
+			Expect(testSink.Logs()[1].Data).NotTo(HaveKey("error"))
+		})
+
+		It("is not modified by sinks that rewrite data", func() {
+			redactingSink, err := lager.NewRedactingSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG), []string{"foo"}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			session.RegisterSink(redactingSink)
+
+			session.Info("redacted")
+			session.Info("redacted-again")
+
+			Expect(testSink.Logs()[0].Data["foo"]).To(Equal("bar"))
+			Expect(testSink.Logs()[1].Data["foo"]).To(Equal("bar"))
+		})
 	})
 
 	Describe("WithTraceInfo", func() {
@@ -444,5 +655,180 @@ var _ = Describe("Logger", func() {
 				Expect(log.Data).To(BeEmpty())
 			})
 		})
+
+		Context("when request contains a W3C traceparent", func() {
+			BeforeEach(func() {
+				req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+				req.Header.Add("tracestate", "rojo=00f067aa0ba902b7")
+				req.Header.Add("tracestate", "congo=t61rcWkgMzE")
+			})
+
+			It("sets the trace id, parent span id, sampled flag and trace state", func() {
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				log := testSink.Logs()[0]
+
+				Expect(log.Data["trace-id"]).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+				Expect(log.Data["parent-span-id"]).To(Equal("00f067aa0ba902b7"))
+				Expect(log.Data["sampled"]).To(BeTrue())
+				Expect(log.Data["trace-state"]).To(Equal("rojo=00f067aa0ba902b7,congo=t61rcWkgMzE"))
+				Expect(log.Data["span-id"]).To(MatchRegexp("^[0-9a-f]{16}$"))
+				Expect(log.Data["span-id"]).NotTo(Equal("00f067aa0ba902b7"))
+			})
+
+			It("takes precedence over B3 and X-Vcap-Request-Id", func() {
+				req.Header.Set("b3", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1")
+				req.Header.Set("X-Vcap-Request-Id", "7f461654-74d1-1ee5-8367-77d85df2cdab")
+
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				Expect(testSink.Logs()[0].Data["trace-id"]).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			})
+
+			It("records an unsampled trace", func() {
+				req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				Expect(testSink.Logs()[0].Data["sampled"]).To(BeFalse())
+			})
+
+			DescribeTable("falls back to the next format when the traceparent is invalid",
+				func(traceparent string) {
+					req.Header.Set("traceparent", traceparent)
+					req.Header.Set("X-Vcap-Request-Id", "7f461654-74d1-1ee5-8367-77d85df2cdab")
+
+					logger = logger.WithTraceInfo(req)
+					logger.Info("test-log")
+
+					log := testSink.Logs()[0]
+					Expect(log.Data["trace-id"]).To(Equal("7f46165474d11ee5836777d85df2cdab"))
+					Expect(log.Data).NotTo(HaveKey("parent-span-id"))
+					Expect(log.Data).NotTo(HaveKey("trace-state"))
+				},
+				Entry("uppercase hex", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01"),
+				Entry("zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"),
+				Entry("zero parent id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"),
+				Entry("invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+				Entry("trailing data on version 00", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"),
+				Entry("too short", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"),
+			)
+
+			It("accepts trailing data on future versions", func() {
+				req.Header.Set("traceparent", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				Expect(testSink.Logs()[0].Data["trace-id"]).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			})
+		})
+
+		Context("when request contains a B3 single header", func() {
+			It("sets the trace id, parent span id and sampled flag", func() {
+				req.Header.Set("b3", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-0-05e3ac9a4f6e3b90")
+				req.Header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+				req.Header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				log := testSink.Logs()[0]
+
+				Expect(log.Data["trace-id"]).To(Equal("80f198ee56343ba864fe8b2a57d3eff7"))
+				Expect(log.Data["parent-span-id"]).To(Equal("e457b5a2e4d86bd1"))
+				Expect(log.Data["sampled"]).To(BeFalse())
+				Expect(log.Data["span-id"]).NotTo(BeEmpty())
+			})
+
+			It("treats the debug sampling state as sampled", func() {
+				req.Header.Set("b3", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-d")
+
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				Expect(testSink.Logs()[0].Data["sampled"]).To(BeTrue())
+			})
+
+			DescribeTable("falls back to B3 multiple headers when the header is invalid",
+				func(header string) {
+					req.Header.Set("b3", header)
+					req.Header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+					req.Header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+
+					logger = logger.WithTraceInfo(req)
+					logger.Info("test-log")
+
+					Expect(testSink.Logs()[0].Data["trace-id"]).To(Equal("463ac35c9f6413ad48485a3953bb6124"))
+				},
+				Entry("sampling state only", "1"),
+				Entry("zero span id", "80f198ee56343ba864fe8b2a57d3eff7-0000000000000000"),
+				Entry("unknown sampling state", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-yes"),
+				Entry("invalid parent span id", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-xyz"),
+				Entry("too long trace id", "80f198ee56343ba864fe8b2a57d3eff7a-e457b5a2e4d86bd1"),
+				Entry("too many fields", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90-1"),
+			)
+		})
+
+		Context("when request contains B3 multiple headers", func() {
+			BeforeEach(func() {
+				req.Header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+				req.Header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+				req.Header.Set("X-Vcap-Request-Id", "7f461654-74d1-1ee5-8367-77d85df2cdab")
+			})
+
+			It("sets the trace id and parent span id", func() {
+				req.Header.Set("X-B3-Sampled", "1")
+
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				log := testSink.Logs()[0]
+
+				Expect(log.Data["trace-id"]).To(Equal("463ac35c9f6413ad48485a3953bb6124"))
+				Expect(log.Data["parent-span-id"]).To(Equal("a2fb4a1d1a96d312"))
+				Expect(log.Data["sampled"]).To(BeTrue())
+			})
+
+			It("accepts true and false as sampling decisions", func() {
+				req.Header.Set("X-B3-Sampled", "false")
+
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				Expect(testSink.Logs()[0].Data["sampled"]).To(BeFalse())
+			})
+
+			It("treats the debug flag as sampled", func() {
+				req.Header.Set("X-B3-Flags", "1")
+
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				Expect(testSink.Logs()[0].Data["sampled"]).To(BeTrue())
+			})
+
+			It("leaves out the sampled flag when there is no sampling decision", func() {
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				Expect(testSink.Logs()[0].Data).NotTo(HaveKey("sampled"))
+			})
+
+			It("falls back to X-Vcap-Request-Id when the headers are invalid", func() {
+				req.Header.Set("X-B3-SpanId", "not-hex")
+
+				logger = logger.WithTraceInfo(req)
+				logger.Info("test-log")
+
+				log := testSink.Logs()[0]
+
+				Expect(log.Data["trace-id"]).To(Equal("7f46165474d11ee5836777d85df2cdab"))
+				Expect(log.Data).NotTo(HaveKey("parent-span-id"))
+			})
+		})
 	})
 })
@@ -13,6 +13,7 @@ type LogLevel int
 const (
 	DEBUG LogLevel = iota
 	INFO
+	WARN
 	ERROR
 	FATAL
 )
@@ -20,6 +21,7 @@ const (
 var logLevelStr = [...]string{
 	DEBUG: "debug",
 	INFO:  "info",
+	WARN:  "warn",
 	ERROR: "error",
 	FATAL: "fatal",
 }
@@ -40,24 +42,52 @@ func LogLevelFromString(s string) (LogLevel, error) {
 	return -1, fmt.Errorf("invalid log level: %s", s)
 }
 
-type Data map[string]interface{}
-
-type rfc3339Time time.Time
+// logLevelNumbers are the values written in the numeric log_level field.
+// WARN is written as 1.5 so that the levels that came before it keep their
+// numbers and stay in order.
+var logLevelNumbers = [...]string{
+	DEBUG: "0",
+	INFO:  "1",
+	WARN:  "1.5",
+	ERROR: "2",
+	FATAL: "3",
+}
 
-const rfc3339Nano = "2006-01-02T15:04:05.000000000Z07:00"
+func (l LogLevel) appendNumber(dst []byte) []byte {
+	if DEBUG <= l && l <= FATAL {
+		return append(dst, logLevelNumbers[l]...)
+	}
+	return strconv.AppendInt(dst, int64(l), 10)
+}
 
-func (t rfc3339Time) MarshalJSON() ([]byte, error) {
-	// Use AppendFormat to avoid slower string operations, instead we only
-	// operate on a byte slice
-	// Avoid creating a new copy of t with a cast, instead use type conversion
-	stamp := append((time.Time)(t).UTC().AppendFormat([]byte{'"'}, rfc3339Nano), '"')
-	return stamp, nil
+// MarshalJSON writes the level as the number in the log_level field, e.g. 2
+// for ERROR.
+func (l LogLevel) MarshalJSON() ([]byte, error) {
+	return l.appendNumber(nil), nil
 }
 
-func (t *rfc3339Time) UnmarshalJSON(data []byte) error {
-	return (*time.Time)(t).UnmarshalJSON(data)
+// UnmarshalJSON reads the number in the log_level field.
+func (l *LogLevel) UnmarshalJSON(data []byte) error {
+	number := string(data)
+	for k, v := range logLevelNumbers {
+		if v == number {
+			*l = LogLevel(k)
+			return nil
+		}
+	}
+
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %s", number)
+	}
+	*l = LogLevel(n)
+	return nil
 }
 
+type Data map[string]interface{}
+
+const rfc3339Nano = "2006-01-02T15:04:05.000000000Z07:00"
+
 type LogFormat struct {
 	Timestamp string   `json:"timestamp"`
 	Source    string   `json:"source"`
@@ -69,52 +99,19 @@ type LogFormat struct {
 }
 
 func (log LogFormat) ToJSON() []byte {
-	content, err := json.Marshal(log)
-	if err != nil {
-		log.Data = dataForJSONMarhallingError(err, log.Data)
-		content, err = json.Marshal(log)
-		if err != nil {
-			panic(err)
-		}
-	}
-	return content
-}
+	buf := getBuffer()
+	defer putBuffer(buf)
 
-type prettyLogFormat struct {
-	Timestamp rfc3339Time `json:"timestamp"`
-	Level     string      `json:"level"`
-	Source    string      `json:"source"`
-	Message   string      `json:"message"`
-	Data      Data        `json:"data"`
-	Error     error       `json:"-"`
+	*buf = log.appendJSON(*buf)
+	return append([]byte(nil), *buf...)
 }
 
 func (log LogFormat) toPrettyJSON() []byte {
-	t := log.time
-	if t.IsZero() {
-		t = parseTimestamp(log.Timestamp)
-	}
-
-	prettyLog := prettyLogFormat{
-		Timestamp: rfc3339Time(t),
-		Level:     log.LogLevel.String(),
-		Source:    log.Source,
-		Message:   log.Message,
-		Data:      log.Data,
-		Error:     log.Error,
-	}
-
-	content, err := json.Marshal(prettyLog)
-
-	if err != nil {
-		prettyLog.Data = dataForJSONMarhallingError(err, prettyLog.Data)
-		content, err = json.Marshal(prettyLog)
-		if err != nil {
-			panic(err)
-		}
-	}
+	buf := getBuffer()
+	defer putBuffer(buf)
 
-	return content
+	*buf = log.appendPrettyJSON(*buf)
+	return append([]byte(nil), *buf...)
 }
 
 func dataForJSONMarhallingError(err error, data Data) Data {
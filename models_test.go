@@ -0,0 +1,95 @@
+package lager_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type marshalerValue struct{}
+
+func (marshalerValue) MarshalJSON() ([]byte, error) { return []byte(`{"custom":true}`), nil }
+
+type stringer string
+
+var _ = Describe("LogLevel", func() {
+	DescribeTable("writes the numbers that levels had before WARN was added",
+		func(level lager.LogLevel, number string) {
+			log := lager.LogFormat{LogLevel: level}
+			Expect(string(log.ToJSON())).To(ContainSubstring(`"log_level":` + number + `,`))
+
+			var decoded lager.LogFormat
+			Expect(json.Unmarshal(log.ToJSON(), &decoded)).To(Succeed())
+			Expect(decoded.LogLevel).To(Equal(level))
+		},
+		Entry("debug", lager.DEBUG, "0"),
+		Entry("info", lager.INFO, "1"),
+		Entry("warn", lager.WARN, "1.5"),
+		Entry("error", lager.ERROR, "2"),
+		Entry("fatal", lager.FATAL, "3"),
+	)
+
+	It("fails to read a number that is not a level", func() {
+		var level lager.LogLevel
+		Expect(json.Unmarshal([]byte(`2.5`), &level)).To(MatchError("invalid log level: 2.5"))
+	})
+})
+
+var _ = Describe("LogFormat", func() {
+	Describe("ToJSON", func() {
+		DescribeTable("produces the same output as json.Marshal",
+			func(data lager.Data) {
+				log := lager.LogFormat{
+					Timestamp: "1464388983.540486336",
+					Source:    "<source>",
+					Message:   "message \"quoted\" & escaped\n\t ",
+					LogLevel:  lager.WARN,
+					Data:      data,
+				}
+
+				expected, err := json.Marshal(log)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(log.ToJSON())).To(Equal(string(expected)))
+			},
+			Entry("nil data", nil),
+			Entry("empty data", lager.Data{}),
+			Entry("strings", lager.Data{"b": "two", "a": "one", "c": "<html> & \x01 \xff ünïcode"}),
+			Entry("numbers", lager.Data{
+				"int": -7, "int8": int8(8), "int16": int16(16), "int32": int32(32), "int64": int64(64),
+				"uint": uint(7), "uint8": uint8(8), "uint16": uint16(16), "uint32": uint32(32), "uint64": uint64(64),
+				"float32": float32(0.1), "float64": 0.25, "tiny": 1e-7, "huge": 1e21, "zero": 0.0,
+				"duration": 3 * time.Second,
+			}),
+			Entry("booleans and nulls", lager.Data{"true": true, "false": false, "nil": nil}),
+			Entry("nested values", lager.Data{
+				"data":  lager.Data{"z": 1, "a": []interface{}{"x", 2, nil, map[string]interface{}{"k": "v"}}},
+				"empty": []interface{}{},
+			}),
+			Entry("more keys than fit on the stack", lager.Data{
+				"k01": 1, "k02": 2, "k03": 3, "k04": 4, "k05": 5, "k06": 6, "k07": 7, "k08": 8, "k09": 9,
+				"k10": 10, "k11": 11, "k12": 12, "k13": 13, "k14": 14, "k15": 15, "k16": 16, "k17": 17,
+			}),
+			Entry("other types", lager.Data{
+				"marshaler": marshalerValue{},
+				"named":     stringer("named"),
+				"struct":    struct{ A string }{"a"},
+				"error":     errors.New("boom"),
+				"slice":     []string{"a", "b"},
+				"time":      time.Unix(0, 0).UTC(),
+			}),
+		)
+
+		It("reports values that cannot be encoded", func() {
+			log := lager.LogFormat{Data: lager.Data{"nan": math.NaN()}}
+
+			var decoded map[string]interface{}
+			Expect(json.Unmarshal(log.ToJSON(), &decoded)).To(Succeed())
+			Expect(decoded["data"]).To(HaveKeyWithValue("unknown_error", "json: unsupported value: NaN"))
+		})
+	})
+})
@@ -0,0 +1,353 @@
+package lager
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultNetworkBufferSize = 1024 * 1024
+	defaultNetworkMinBackoff = 100 * time.Millisecond
+	defaultNetworkMaxBackoff = 30 * time.Second
+	defaultNetworkTimeout    = 5 * time.Second
+
+	maxNetworkBatchSize = 64 * 1024
+)
+
+var errNetworkSinkFlushTimeout = errors.New("timed out flushing network sink")
+
+// NetworkSinkConfig describes where a NetworkSink sends logs.
+type NetworkSinkConfig struct {
+	// Network is "tcp" or "unix".
+	Network string
+	// Address is the host:port, or the socket path, of the log aggregator.
+	Address     string
+	MinLogLevel LogLevel
+
+	// TLSConfig, when set, wraps tcp connections in TLS.
+	TLSConfig *tls.Config
+
+	// BufferSize is the number of bytes of logs held while the sink is
+	// disconnected or the aggregator is slow. Logs that do not fit are
+	// dropped. It defaults to 1MiB.
+	BufferSize int
+	// MinBackoff and MaxBackoff bound the wait between attempts to connect,
+	// which doubles after every failure. They default to 100ms and 30s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Timeout bounds connecting, each write and Flush. It defaults to five
+	// seconds.
+	Timeout time.Duration
+}
+
+// NetworkSink is a Sink that streams logs, one JSON object per line as
+// written by NewWriterSink, to a log aggregator. Logs are buffered and sent
+// by a background goroutine, which reconnects whenever the connection fails.
+type NetworkSink struct {
+	config NetworkSinkConfig
+
+	lock   sync.Mutex
+	queue  [][]byte
+	size   int
+	closed bool
+
+	// queued counts the logs ever queued and handled the ones sent or
+	// dropped since, so that Flush can wait for the logs queued before it
+	// without waiting for the queue to empty. progress is closed and
+	// replaced whenever handled grows.
+	queued   uint64
+	handled  uint64
+	progress chan struct{}
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+
+	dropped uint64
+}
+
+// NewNetworkSink starts a goroutine that connects to config.Address and
+// returns a NetworkSink that sends logs to it. The sink accepts logs before
+// the connection is made.
+//
+// Close must be called to send the buffered logs and stop the goroutine.
+func NewNetworkSink(config NetworkSinkConfig) (*NetworkSink, error) {
+	switch config.Network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, errors.New("unsupported network for network sink: " + config.Network)
+	}
+
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultNetworkBufferSize
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaultNetworkMinBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultNetworkMaxBackoff
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultNetworkTimeout
+	}
+
+	sink := &NetworkSink{
+		config:   config,
+		progress: make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go sink.run()
+
+	return sink, nil
+}
+
+// Log queues the log to be sent. Logs written after Close are discarded.
+func (sink *NetworkSink) Log(log LogFormat) {
+	if log.LogLevel < sink.config.MinLogLevel {
+		return
+	}
+
+	// Convert to json outside of critical section to minimize time spent holding lock
+	buf := getBuffer()
+	*buf = log.appendJSON(*buf)
+	line := make([]byte, len(*buf)+1)
+	copy(line, *buf)
+	line[len(line)-1] = '\n'
+	putBuffer(buf)
+
+	sink.lock.Lock()
+	if sink.closed {
+		sink.lock.Unlock()
+		return
+	}
+	if sink.size+len(line) > sink.config.BufferSize {
+		sink.lock.Unlock()
+		atomic.AddUint64(&sink.dropped, 1)
+		return
+	}
+	sink.queue = append(sink.queue, line)
+	sink.size += len(line)
+	sink.queued++
+	sink.lock.Unlock()
+
+	select {
+	case sink.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (sink *NetworkSink) Enabled(level LogLevel) bool {
+	return level >= sink.config.MinLogLevel
+}
+
+// Dropped returns the number of logs discarded because the buffer was full,
+// or because they could not be sent before the sink was closed.
+func (sink *NetworkSink) Dropped() uint64 {
+	return atomic.LoadUint64(&sink.dropped)
+}
+
+// Flush blocks until every log buffered before the call has been sent, or
+// dropped, or returns an error once the configured Timeout has passed. Logs
+// buffered while Flush waits do not hold it up.
+func (sink *NetworkSink) Flush() error {
+	timer := time.NewTimer(sink.config.Timeout)
+	defer timer.Stop()
+
+	sink.lock.Lock()
+	queued := sink.queued
+	for sink.handled < queued {
+		progress := sink.progress
+		sink.lock.Unlock()
+
+		select {
+		case <-progress:
+		case <-sink.done:
+			return nil
+		case <-timer.C:
+			return errNetworkSinkFlushTimeout
+		}
+
+		sink.lock.Lock()
+	}
+	sink.lock.Unlock()
+
+	return nil
+}
+
+// Close sends the buffered logs, if the sink is connected or can connect
+// once more, and stops the background goroutine. Logs that cannot be sent are
+// counted as dropped. It is safe to call Close more than once.
+func (sink *NetworkSink) Close() error {
+	sink.lock.Lock()
+	if !sink.closed {
+		sink.closed = true
+		close(sink.stop)
+	}
+	sink.lock.Unlock()
+
+	<-sink.done
+	return nil
+}
+
+func (sink *NetworkSink) run() {
+	defer close(sink.done)
+
+	var conn net.Conn
+	var connClosed chan struct{}
+	defer func() {
+		if conn != nil {
+			conn.Close() //nolint:errcheck
+		}
+	}()
+
+	backoff := sink.config.MinBackoff
+
+	for {
+		batch, stopping := sink.next()
+		if len(batch) == 0 {
+			return
+		}
+
+		if conn != nil {
+			select {
+			case <-connClosed:
+				// the aggregator hung up while the connection was idle
+				conn.Close() //nolint:errcheck
+				conn = nil
+			default:
+			}
+		}
+
+		if conn == nil {
+			var err error
+			conn, err = sink.dial()
+			if err != nil {
+				if stopping {
+					sink.dropAll()
+					return
+				}
+				sink.sleep(backoff)
+				backoff *= 2
+				if backoff > sink.config.MaxBackoff {
+					backoff = sink.config.MaxBackoff
+				}
+				continue
+			}
+			backoff = sink.config.MinBackoff
+			connClosed = watchClosed(conn)
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(sink.config.Timeout)) //nolint:errcheck
+		buffers := net.Buffers(batch)
+		if _, err := buffers.WriteTo(conn); err != nil {
+			conn.Close() //nolint:errcheck
+			conn = nil
+			if stopping {
+				sink.dropAll()
+				return
+			}
+			continue
+		}
+
+		sink.remove(len(batch))
+	}
+}
+
+// next waits for logs to send and returns the oldest of them, leaving them in
+// the queue until they have been sent. It returns no logs once the sink is
+// closed and the queue is empty.
+func (sink *NetworkSink) next() ([][]byte, bool) {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	for len(sink.queue) == 0 && !sink.closed {
+		sink.lock.Unlock()
+		select {
+		case <-sink.wake:
+		case <-sink.stop:
+		}
+		sink.lock.Lock()
+	}
+
+	size := 0
+	n := 0
+	for n < len(sink.queue) && (n == 0 || size+len(sink.queue[n]) <= maxNetworkBatchSize) {
+		size += len(sink.queue[n])
+		n++
+	}
+
+	return append([][]byte(nil), sink.queue[:n]...), sink.closed
+}
+
+// remove discards the n oldest logs once they have been sent.
+func (sink *NetworkSink) remove(n int) {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	for _, line := range sink.queue[:n] {
+		sink.size -= len(line)
+	}
+	sink.queue = append(sink.queue[:0], sink.queue[n:]...)
+	sink.handle(n)
+}
+
+// dropAll discards the logs that could not be sent before the sink closed.
+func (sink *NetworkSink) dropAll() {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	atomic.AddUint64(&sink.dropped, uint64(len(sink.queue)))
+	sink.handle(len(sink.queue))
+	sink.queue = nil
+	sink.size = 0
+}
+
+// handle records that n logs have left the queue and wakes Flush. The caller
+// must hold the lock.
+func (sink *NetworkSink) handle(n int) {
+	sink.handled += uint64(n)
+	close(sink.progress)
+	sink.progress = make(chan struct{})
+}
+
+func (sink *NetworkSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: sink.config.Timeout}
+	if sink.config.TLSConfig != nil && sink.config.Network != "unix" {
+		return tls.DialWithDialer(dialer, sink.config.Network, sink.config.Address, sink.config.TLSConfig)
+	}
+	return dialer.Dial(sink.config.Network, sink.config.Address)
+}
+
+// sleep waits for d, or until the sink is closed.
+func (sink *NetworkSink) sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-sink.stop:
+	}
+}
+
+// watchClosed returns a channel that is closed when the other end closes
+// the connection. Aggregators are not expected to send anything, so whatever
+// they do send is discarded.
+func watchClosed(conn net.Conn) chan struct{} {
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn) //nolint:errcheck
+		close(closed)
+	}()
+	return closed
+}
@@ -0,0 +1,343 @@
+package lager_test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NetworkSink", func() {
+	var (
+		config lager.NetworkSinkConfig
+		sink   *lager.NetworkSink
+		log    lager.LogFormat
+	)
+
+	BeforeEach(func() {
+		config = lager.NetworkSinkConfig{
+			Network:     "tcp",
+			MinLogLevel: lager.INFO,
+			MinBackoff:  10 * time.Millisecond,
+			MaxBackoff:  50 * time.Millisecond,
+			Timeout:     time.Second,
+		}
+		log = lager.LogFormat{
+			Timestamp: "1709296496.789000000",
+			Source:    "my-app",
+			Message:   "my-app.did-something",
+			LogLevel:  lager.INFO,
+			Data:      lager.Data{"count": 3},
+		}
+	})
+
+	AfterEach(func() {
+		if sink != nil {
+			Expect(sink.Close()).To(Succeed())
+			sink = nil
+		}
+	})
+
+	newSink := func() {
+		var err error
+		sink, err = lager.NewNetworkSink(config)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// readLines reads lines from every connection accepted by the listener.
+	// Sending to drop closes the connections accepted so far.
+	readLines := func(listener net.Listener) (chan string, chan struct{}) {
+		lines := make(chan string, 100)
+		drop := make(chan struct{})
+		conns := make(chan net.Conn, 10)
+
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conns <- conn
+				go func() {
+					scanner := bufio.NewScanner(conn)
+					for scanner.Scan() {
+						lines <- scanner.Text()
+					}
+				}()
+			}
+		}()
+
+		go func() {
+			for range drop {
+				for len(conns) > 0 {
+					(<-conns).Close()
+				}
+			}
+		}()
+		DeferCleanup(func() {
+			close(drop)
+			for len(conns) > 0 {
+				(<-conns).Close()
+			}
+		})
+
+		return lines, drop
+	}
+
+	// freeAddress returns an address that nothing is listening on.
+	freeAddress := func() string {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		address := listener.Addr().String()
+		Expect(listener.Close()).To(Succeed())
+		return address
+	}
+
+	Context("when the aggregator is listening", func() {
+		var (
+			lines chan string
+			drop  chan struct{}
+		)
+
+		BeforeEach(func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(listener.Close)
+
+			lines, drop = readLines(listener)
+			config.Address = listener.Addr().String()
+		})
+
+		It("sends each log as a line of JSON", func() {
+			newSink()
+			sink.Log(log)
+
+			Eventually(lines).Should(Receive(MatchJSON(log.ToJSON())))
+		})
+
+		It("does not send logs below the minimum level", func() {
+			newSink()
+			log.LogLevel = lager.DEBUG
+			sink.Log(log)
+
+			Consistently(lines, 100*time.Millisecond).ShouldNot(Receive())
+			Expect(sink.Enabled(lager.DEBUG)).To(BeFalse())
+			Expect(sink.Enabled(lager.INFO)).To(BeTrue())
+		})
+
+		It("reconnects when the aggregator closes the connection", func() {
+			newSink()
+			sink.Log(log)
+			Eventually(lines).Should(Receive())
+
+			drop <- struct{}{}
+
+			Eventually(func() chan string {
+				sink.Log(log)
+				return lines
+			}).Should(Receive(MatchJSON(log.ToJSON())))
+		})
+
+		It("sends everything before Flush returns", func() {
+			newSink()
+			for i := 0; i < 100; i++ {
+				sink.Log(log)
+			}
+			Expect(sink.Flush()).To(Succeed())
+
+			Eventually(lines).Should(HaveLen(100))
+		})
+
+		It("does not wait for logs buffered while Flush runs", func() {
+			log.Data = lager.Data{"padding": strings.Repeat("a", 32*1024)}
+			config.BufferSize = 100 * (len(log.ToJSON()) + 1)
+			config.Timeout = 2 * time.Second
+			newSink()
+			defer func() {
+				go func() {
+					for range lines {
+					}
+				}()
+			}()
+
+			sink.Log(log)
+			Eventually(lines).Should(Receive())
+
+			// fill the connection, which is not read from until Flush is
+			// called, until every log is dropped, so that the buffer cannot
+			// empty
+			dropping := 0
+			Eventually(func() int {
+				dropped := sink.Dropped()
+				sink.Log(log)
+				if sink.Dropped() > dropped {
+					dropping++
+				} else {
+					dropping = 0
+				}
+				return dropping
+			}).WithTimeout(10 * time.Second).WithPolling(time.Millisecond).Should(BeNumerically(">=", 50))
+
+			flushed := make(chan error, 1)
+			go func() {
+				flushed <- sink.Flush()
+			}()
+
+			// log faster than the lines are read
+			for {
+				select {
+				case err := <-flushed:
+					Expect(err).NotTo(HaveOccurred())
+					return
+				case <-lines:
+					for i := 0; i < 8; i++ {
+						sink.Log(log)
+					}
+				}
+			}
+		})
+
+		It("sends the buffered logs when it is closed and discards later logs", func() {
+			newSink()
+			for i := 0; i < 10; i++ {
+				sink.Log(log)
+			}
+			Expect(sink.Close()).To(Succeed())
+			Expect(sink.Close()).To(Succeed())
+			sink.Log(log)
+
+			Eventually(lines).Should(HaveLen(10))
+			Consistently(lines, 100*time.Millisecond).Should(HaveLen(10))
+			Expect(sink.Dropped()).To(BeZero())
+		})
+	})
+
+	Context("when the aggregator is not listening", func() {
+		BeforeEach(func() {
+			config.Address = freeAddress()
+		})
+
+		It("buffers logs until it can connect", func() {
+			newSink()
+			first := log.ToJSON()
+			sink.Log(log)
+			log.Message = "my-app.did-something-else"
+			sink.Log(log)
+
+			time.Sleep(100 * time.Millisecond)
+
+			listener, err := net.Listen("tcp", config.Address)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(listener.Close)
+			lines, _ := readLines(listener)
+
+			Eventually(lines).Should(Receive(MatchJSON(first)))
+			Eventually(lines).Should(Receive(MatchJSON(log.ToJSON())))
+		})
+
+		It("drops logs that do not fit in the buffer and counts them", func() {
+			config.BufferSize = 2 * (len(log.ToJSON()) + 1)
+			newSink()
+
+			for i := 0; i < 5; i++ {
+				sink.Log(log)
+			}
+
+			Expect(sink.Dropped()).To(BeEquivalentTo(3))
+		})
+
+		It("times out flushing", func() {
+			config.Timeout = 50 * time.Millisecond
+			newSink()
+			sink.Log(log)
+
+			Expect(sink.Flush()).To(MatchError(ContainSubstring("timed out")))
+		})
+
+		It("counts the logs it could not send as dropped when it is closed", func() {
+			newSink()
+			sink.Log(log)
+			sink.Log(log)
+
+			Expect(sink.Close()).To(Succeed())
+			Expect(sink.Dropped()).To(BeEquivalentTo(2))
+		})
+	})
+
+	It("sends logs over unix sockets", func() {
+		dir, err := os.MkdirTemp("", "network-sink")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+
+		listener, err := net.Listen("unix", filepath.Join(dir, "log.sock"))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(listener.Close)
+		lines, _ := readLines(listener)
+
+		config.Network = "unix"
+		config.Address = listener.Addr().String()
+		newSink()
+		sink.Log(log)
+
+		Eventually(lines).Should(Receive(MatchJSON(log.ToJSON())))
+	})
+
+	It("sends logs over TLS", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "aggregator"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			IsCA:         true,
+
+			BasicConstraintsValid: true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).NotTo(HaveOccurred())
+		cert, err := x509.ParseCertificate(der)
+		Expect(err).NotTo(HaveOccurred())
+
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(listener.Close)
+		lines, _ := readLines(listener)
+
+		pool := x509.NewCertPool()
+		pool.AddCert(cert)
+
+		config.Address = listener.Addr().String()
+		config.TLSConfig = &tls.Config{RootCAs: pool}
+		newSink()
+		sink.Log(log)
+
+		Eventually(lines).Should(Receive(MatchJSON(log.ToJSON())))
+	})
+
+	It("rejects unsupported networks", func() {
+		config.Network = "udp"
+
+		_, err := lager.NewNetworkSink(config)
+		Expect(err).To(MatchError(ContainSubstring("unsupported network")))
+	})
+})
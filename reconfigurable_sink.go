@@ -1,13 +1,58 @@
 package lager
 
 import (
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// LevelOverride sets a different minimum log level for logs from a
+// particular source and/or session.
+type LevelOverride struct {
+	// Source matches LogFormat.Source exactly. Empty matches any source.
+	Source string
+	// Session matches logs from a logger whose SessionName is Session or
+	// starts with Session followed by a dot, e.g. "my-component.request"
+	// matches "my-component.request.fetch". Empty matches any session.
+	Session string
+	// MinLevel is the minimum log level for matching logs.
+	MinLevel LogLevel
+	// Expires is when the override stops applying. The zero value never
+	// expires.
+	Expires time.Time
+}
+
+func (o LevelOverride) matches(log LogFormat) bool {
+	if o.Source != "" && o.Source != log.Source {
+		return false
+	}
+	if o.Session != "" && !strings.HasPrefix(log.Message, o.Session+".") {
+		return false
+	}
+	return true
+}
+
+func (o LevelOverride) expired(now time.Time) bool {
+	return !o.Expires.IsZero() && !now.Before(o.Expires)
+}
+
+// moreSpecificThan prefers the longer session prefix, then an override with a
+// source over one without.
+func (o LevelOverride) moreSpecificThan(other LevelOverride) bool {
+	if len(o.Session) != len(other.Session) {
+		return len(o.Session) > len(other.Session)
+	}
+	return o.Source != "" && other.Source == ""
+}
+
 type ReconfigurableSink struct {
 	sink Sink
 
 	minLogLevel int32
+
+	overridesL sync.Mutex
+	overrides  atomic.Pointer[[]LevelOverride]
 }
 
 func NewReconfigurableSink(sink Sink, initialMinLogLevel LogLevel) *ReconfigurableSink {
@@ -21,6 +66,10 @@ func NewReconfigurableSink(sink Sink, initialMinLogLevel LogLevel) *Reconfigurab
 func (sink *ReconfigurableSink) Log(log LogFormat) {
 	minLogLevel := LogLevel(atomic.LoadInt32(&sink.minLogLevel))
 
+	if overrides := sink.overrides.Load(); overrides != nil {
+		minLogLevel = sink.levelFor(log, minLogLevel, *overrides)
+	}
+
 	if log.LogLevel < minLogLevel {
 		return
 	}
@@ -28,6 +77,33 @@ func (sink *ReconfigurableSink) Log(log LogFormat) {
 	sink.sink.Log(log)
 }
 
+// Enabled reports whether some log at the level could get through, taking the
+// lowest level of any override into account.
+func (sink *ReconfigurableSink) Enabled(level LogLevel) bool {
+	minLogLevel := LogLevel(atomic.LoadInt32(&sink.minLogLevel))
+
+	if overrides := sink.overrides.Load(); overrides != nil {
+		now := time.Now()
+		for _, o := range *overrides {
+			if o.MinLevel < minLogLevel && !o.expired(now) {
+				minLogLevel = o.MinLevel
+			}
+		}
+	}
+
+	return level >= minLogLevel && SinkEnabled(sink.sink, level)
+}
+
+// Flush flushes the wrapped sink.
+func (sink *ReconfigurableSink) Flush() error {
+	return FlushSink(sink.sink)
+}
+
+// Close closes the wrapped sink.
+func (sink *ReconfigurableSink) Close() error {
+	return CloseSink(sink.sink)
+}
+
 func (sink *ReconfigurableSink) SetMinLevel(level LogLevel) {
 	atomic.StoreInt32(&sink.minLogLevel, int32(level))
 }
@@ -35,3 +111,91 @@ func (sink *ReconfigurableSink) SetMinLevel(level LogLevel) {
 func (sink *ReconfigurableSink) GetMinLevel() LogLevel {
 	return LogLevel(atomic.LoadInt32(&sink.minLogLevel))
 }
+
+// SetOverride adds an override, replacing any existing override for the same
+// Source and Session. When several overrides match a log the most specific
+// one wins.
+func (sink *ReconfigurableSink) SetOverride(override LevelOverride) {
+	sink.updateOverrides(func(overrides []LevelOverride) []LevelOverride {
+		overrides = removeOverride(overrides, override.Source, override.Session)
+		return append(overrides, override)
+	})
+}
+
+// RemoveOverride removes the override for the given source and session, if
+// there is one.
+func (sink *ReconfigurableSink) RemoveOverride(source, session string) {
+	sink.updateOverrides(func(overrides []LevelOverride) []LevelOverride {
+		return removeOverride(overrides, source, session)
+	})
+}
+
+// Overrides returns the overrides that have not yet expired.
+func (sink *ReconfigurableSink) Overrides() []LevelOverride {
+	sink.updateOverrides(func(overrides []LevelOverride) []LevelOverride {
+		return overrides
+	})
+
+	overrides := sink.overrides.Load()
+	if overrides == nil {
+		return nil
+	}
+	return append([]LevelOverride(nil), *overrides...)
+}
+
+func (sink *ReconfigurableSink) levelFor(log LogFormat, level LogLevel, overrides []LevelOverride) LogLevel {
+	now := time.Now()
+
+	var best *LevelOverride
+	for i := range overrides {
+		o := &overrides[i]
+		if o.expired(now) || !o.matches(log) {
+			continue
+		}
+		if best == nil || o.moreSpecificThan(*best) {
+			best = o
+		}
+	}
+
+	if best == nil {
+		return level
+	}
+	return best.MinLevel
+}
+
+// updateOverrides applies the change to a copy of the overrides, dropping any
+// that have expired, and publishes the result for Log to read without locking.
+func (sink *ReconfigurableSink) updateOverrides(change func([]LevelOverride) []LevelOverride) {
+	sink.overridesL.Lock()
+	defer sink.overridesL.Unlock()
+
+	var current []LevelOverride
+	if overrides := sink.overrides.Load(); overrides != nil {
+		current = *overrides
+	}
+
+	now := time.Now()
+	updated := make([]LevelOverride, 0, len(current)+1)
+	for _, o := range current {
+		if !o.expired(now) {
+			updated = append(updated, o)
+		}
+	}
+
+	updated = change(updated)
+	if len(updated) == 0 {
+		sink.overrides.Store(nil)
+		return
+	}
+	sink.overrides.Store(&updated)
+}
+
+func removeOverride(overrides []LevelOverride, source, session string) []LevelOverride {
+	kept := overrides[:0]
+	for _, o := range overrides {
+		if o.Source != source || o.Session != session {
+			kept = append(kept, o)
+		}
+	}
+	return kept
+}
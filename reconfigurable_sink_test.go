@@ -1,6 +1,8 @@
 package lager_test
 
 import (
+	"time"
+
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagertest"
 	. "github.com/onsi/ginkgo/v2"
@@ -62,4 +64,98 @@ var _ = Describe("ReconfigurableSink", func() {
 			Expect(sink.GetMinLevel()).To(Equal(lager.DEBUG))
 		})
 	})
+
+	Describe("overrides", func() {
+		var logger lager.Logger
+
+		BeforeEach(func() {
+			logger = lager.NewLogger("component")
+			logger.RegisterSink(sink)
+		})
+
+		It("has none by default", func() {
+			Expect(sink.Overrides()).To(BeEmpty())
+		})
+
+		Context("with a session override", func() {
+			BeforeEach(func() {
+				sink.SetOverride(lager.LevelOverride{Session: "component.request", MinLevel: lager.DEBUG})
+			})
+
+			It("applies the override to the session and its children", func() {
+				request := logger.Session("request")
+				request.Debug("in-session")
+				request.Session("fetch").Debug("in-child-session")
+
+				Expect(testSink.LogMessages()).To(Equal([]string{
+					"component.request.in-session",
+					"component.request.fetch.in-child-session",
+				}))
+			})
+
+			It("uses the default level for other sessions", func() {
+				logger.Debug("outside-session")
+				logger.Session("requests").Debug("similar-session")
+
+				Expect(testSink.LogMessages()).To(BeEmpty())
+			})
+
+			It("can be removed", func() {
+				sink.RemoveOverride("", "component.request")
+				logger.Session("request").Debug("in-session")
+
+				Expect(testSink.LogMessages()).To(BeEmpty())
+				Expect(sink.Overrides()).To(BeEmpty())
+			})
+
+			It("is replaced by a new override for the same session", func() {
+				sink.SetOverride(lager.LevelOverride{Session: "component.request", MinLevel: lager.ERROR})
+				logger.Session("request").Info("in-session")
+
+				Expect(testSink.LogMessages()).To(BeEmpty())
+				Expect(sink.Overrides()).To(HaveLen(1))
+			})
+
+			It("prefers a more specific override", func() {
+				sink.SetOverride(lager.LevelOverride{Session: "component.request.fetch", MinLevel: lager.ERROR})
+				request := logger.Session("request")
+				request.Debug("in-session")
+				request.Session("fetch").Info("in-child-session")
+
+				Expect(testSink.LogMessages()).To(Equal([]string{"component.request.in-session"}))
+			})
+		})
+
+		Context("with a source override", func() {
+			BeforeEach(func() {
+				sink.SetOverride(lager.LevelOverride{Source: "component", MinLevel: lager.ERROR})
+			})
+
+			It("applies the override to logs from the source", func() {
+				logger.Info("from-component")
+				sink.Log(lager.LogFormat{Source: "other", LogLevel: lager.INFO, Message: "from-other"})
+
+				Expect(testSink.LogMessages()).To(Equal([]string{"from-other"}))
+			})
+		})
+
+		Context("with an override that expires", func() {
+			BeforeEach(func() {
+				sink.SetOverride(lager.LevelOverride{
+					Session:  "component",
+					MinLevel: lager.DEBUG,
+					Expires:  time.Now().Add(100 * time.Millisecond),
+				})
+			})
+
+			It("reverts to the default level once expired", func() {
+				logger.Debug("before-expiry")
+				Expect(testSink.LogMessages()).To(HaveLen(1))
+
+				Eventually(sink.Overrides).Should(BeEmpty())
+				logger.Debug("after-expiry")
+				Expect(testSink.LogMessages()).To(HaveLen(1))
+			})
+		})
+	})
 })
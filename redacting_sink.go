@@ -52,10 +52,25 @@ func (sink *redactingSink) Log(log LogFormat) {
 
 	redactedJSON := sink.jsonRedacter.Redact(rawJSON)
 
-	err = json.Unmarshal(redactedJSON, &log.Data)
+	// Unmarshal into a new map as log.Data may be shared with other sinks
+	var redactedData Data
+	err = json.Unmarshal(redactedJSON, &redactedData)
 	if err != nil {
 		panic(err)
 	}
+	log.Data = redactedData
 
 	sink.sink.Log(log)
 }
+
+func (sink *redactingSink) Enabled(level LogLevel) bool {
+	return SinkEnabled(sink.sink, level)
+}
+
+func (sink *redactingSink) Flush() error {
+	return FlushSink(sink.sink)
+}
+
+func (sink *redactingSink) Close() error {
+	return CloseSink(sink.sink)
+}
@@ -0,0 +1,165 @@
+package lager
+
+import (
+	"errors"
+	"strings"
+)
+
+// LogPredicate reports whether a log matches a Route.
+type LogPredicate func(log LogFormat) bool
+
+// Route sends the logs that Match accepts to Sink. A nil Match accepts every
+// log.
+type Route struct {
+	Match LogPredicate
+	Sink  Sink
+}
+
+// RouteMode decides how many routes of a routing sink a log is sent to.
+type RouteMode int
+
+const (
+	// RouteAllMatches sends each log to every route that matches it.
+	RouteAllMatches RouteMode = iota
+	// RouteFirstMatch sends each log to the first route that matches it
+	// only, so that a final route with a nil Match catches everything else.
+	RouteFirstMatch
+)
+
+type routingSink struct {
+	mode   RouteMode
+	routes []Route
+}
+
+// NewRoutingSink returns a sink that sends each log to the sinks of the
+// routes that match it, in order. Logs that match no route are discarded.
+// Example:
+//
+//	sink := lager.NewRoutingSink(lager.RouteFirstMatch,
+//		lager.Route{Match: lager.MatchMinLevel(lager.ERROR), Sink: errorSink},
+//		lager.Route{Match: lager.MatchSession("my-app.request"), Sink: requestSink},
+//		lager.Route{Sink: defaultSink},
+//	)
+func NewRoutingSink(mode RouteMode, routes ...Route) Sink {
+	return &routingSink{
+		mode:   mode,
+		routes: append([]Route(nil), routes...),
+	}
+}
+
+func (sink *routingSink) Log(log LogFormat) {
+	for _, route := range sink.routes {
+		if route.Match != nil && !route.Match(log) {
+			continue
+		}
+
+		route.Sink.Log(log)
+
+		if sink.mode == RouteFirstMatch {
+			return
+		}
+	}
+}
+
+// Enabled reports whether the sink of any route would write logs at the
+// level. Predicates are not consulted, as they need the whole log.
+func (sink *routingSink) Enabled(level LogLevel) bool {
+	for _, route := range sink.routes {
+		if SinkEnabled(route.Sink, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flush flushes the sink of every route.
+func (sink *routingSink) Flush() error {
+	var errs []error
+	for _, route := range sink.routes {
+		errs = append(errs, FlushSink(route.Sink))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the sink of every route.
+func (sink *routingSink) Close() error {
+	var errs []error
+	for _, route := range sink.routes {
+		errs = append(errs, CloseSink(route.Sink))
+	}
+	return errors.Join(errs...)
+}
+
+// MatchMinLevel matches logs at or above the level.
+func MatchMinLevel(level LogLevel) LogPredicate {
+	return func(log LogFormat) bool {
+		return log.LogLevel >= level
+	}
+}
+
+// MatchMaxLevel matches logs at or below the level.
+func MatchMaxLevel(level LogLevel) LogPredicate {
+	return func(log LogFormat) bool {
+		return log.LogLevel <= level
+	}
+}
+
+// MatchSource matches logs from loggers created with the source as their
+// component.
+func MatchSource(source string) LogPredicate {
+	return func(log LogFormat) bool {
+		return log.Source == source
+	}
+}
+
+// MatchMessagePrefix matches logs whose message starts with the prefix.
+func MatchMessagePrefix(prefix string) LogPredicate {
+	return func(log LogFormat) bool {
+		return strings.HasPrefix(log.Message, prefix)
+	}
+}
+
+// MatchSession matches logs from a logger whose SessionName is the session or
+// one nested under it, the same way as LevelOverride.Session.
+func MatchSession(session string) LogPredicate {
+	return MatchMessagePrefix(session + ".")
+}
+
+// MatchDataKey matches logs whose data has the key.
+func MatchDataKey(key string) LogPredicate {
+	return func(log LogFormat) bool {
+		_, ok := log.Data[key]
+		return ok
+	}
+}
+
+// MatchAll matches logs that all of the predicates match.
+func MatchAll(predicates ...LogPredicate) LogPredicate {
+	return func(log LogFormat) bool {
+		for _, p := range predicates {
+			if !p(log) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAny matches logs that any of the predicates match.
+func MatchAny(predicates ...LogPredicate) LogPredicate {
+	return func(log LogFormat) bool {
+		for _, p := range predicates {
+			if p(log) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchNot matches logs that the predicate does not match.
+func MatchNot(predicate LogPredicate) LogPredicate {
+	return func(log LogFormat) bool {
+		return !predicate(log)
+	}
+}
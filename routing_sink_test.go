@@ -0,0 +1,132 @@
+package lager_test
+
+import (
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RoutingSink", func() {
+	var (
+		errorSink   *lagertest.TestSink
+		requestSink *lagertest.TestSink
+		defaultSink *lagertest.TestSink
+		routes      []lager.Route
+		logger      lager.Logger
+	)
+
+	BeforeEach(func() {
+		errorSink = lagertest.NewTestSink()
+		requestSink = lagertest.NewTestSink()
+		defaultSink = lagertest.NewTestSink()
+
+		routes = []lager.Route{
+			{Match: lager.MatchMinLevel(lager.ERROR), Sink: errorSink},
+			{Match: lager.MatchSession("my-app.request"), Sink: requestSink},
+			{Sink: defaultSink},
+		}
+		logger = lager.NewLogger("my-app")
+	})
+
+	logAll := func() {
+		logger.Info("starting")
+		logger.Error("failed", nil)
+
+		request := logger.Session("request")
+		request.Info("serving")
+		request.Session("fetch").Error("failed", nil)
+	}
+
+	Context("when every matching route gets the log", func() {
+		BeforeEach(func() {
+			logger.RegisterSink(lager.NewRoutingSink(lager.RouteAllMatches, routes...))
+			logAll()
+		})
+
+		It("sends each log to all of the routes that match it", func() {
+			Expect(errorSink.LogMessages()).To(Equal([]string{"my-app.failed", "my-app.request.fetch.failed"}))
+			Expect(requestSink.LogMessages()).To(Equal([]string{"my-app.request.serving", "my-app.request.fetch.failed"}))
+			Expect(defaultSink.LogMessages()).To(Equal([]string{
+				"my-app.starting", "my-app.failed", "my-app.request.serving", "my-app.request.fetch.failed",
+			}))
+		})
+	})
+
+	Context("when only the first matching route gets the log", func() {
+		BeforeEach(func() {
+			logger.RegisterSink(lager.NewRoutingSink(lager.RouteFirstMatch, routes...))
+			logAll()
+		})
+
+		It("sends each log to the first route that matches it", func() {
+			Expect(errorSink.LogMessages()).To(Equal([]string{"my-app.failed", "my-app.request.fetch.failed"}))
+			Expect(requestSink.LogMessages()).To(Equal([]string{"my-app.request.serving"}))
+			Expect(defaultSink.LogMessages()).To(Equal([]string{"my-app.starting"}))
+		})
+	})
+
+	It("discards logs that match no route", func() {
+		logger.RegisterSink(lager.NewRoutingSink(lager.RouteAllMatches, routes[:2]...))
+		logger.Info("starting")
+
+		Expect(errorSink.Logs()).To(BeEmpty())
+		Expect(requestSink.Logs()).To(BeEmpty())
+	})
+
+	It("is enabled for the levels that any of its sinks writes", func() {
+		sink := lager.NewRoutingSink(lager.RouteFirstMatch,
+			lager.Route{Match: lager.MatchMinLevel(lager.ERROR), Sink: lager.NewReconfigurableSink(errorSink, lager.ERROR)},
+			lager.Route{Sink: lager.NewReconfigurableSink(defaultSink, lager.INFO)},
+		)
+
+		Expect(sink.(lager.LevelledSink).Enabled(lager.DEBUG)).To(BeFalse())
+		Expect(sink.(lager.LevelledSink).Enabled(lager.INFO)).To(BeTrue())
+	})
+
+	Describe("predicates", func() {
+		var log lager.LogFormat
+
+		BeforeEach(func() {
+			log = lager.LogFormat{
+				Source:   "my-app",
+				Message:  "my-app.request.serving",
+				LogLevel: lager.WARN,
+				Data:     lager.Data{"path": "/v1/things"},
+			}
+		})
+
+		It("matches on level", func() {
+			Expect(lager.MatchMinLevel(lager.WARN)(log)).To(BeTrue())
+			Expect(lager.MatchMinLevel(lager.ERROR)(log)).To(BeFalse())
+			Expect(lager.MatchMaxLevel(lager.WARN)(log)).To(BeTrue())
+			Expect(lager.MatchMaxLevel(lager.INFO)(log)).To(BeFalse())
+		})
+
+		It("matches on source", func() {
+			Expect(lager.MatchSource("my-app")(log)).To(BeTrue())
+			Expect(lager.MatchSource("other-app")(log)).To(BeFalse())
+		})
+
+		It("matches on message prefix and session", func() {
+			Expect(lager.MatchMessagePrefix("my-app.req")(log)).To(BeTrue())
+			Expect(lager.MatchSession("my-app.request")(log)).To(BeTrue())
+			Expect(lager.MatchSession("my-app.req")(log)).To(BeFalse())
+			Expect(lager.MatchSession("my-app.request.serving")(log)).To(BeFalse())
+		})
+
+		It("matches on data keys", func() {
+			Expect(lager.MatchDataKey("path")(log)).To(BeTrue())
+			Expect(lager.MatchDataKey("method")(log)).To(BeFalse())
+		})
+
+		It("combines predicates", func() {
+			warnings := lager.MatchAll(lager.MatchMinLevel(lager.WARN), lager.MatchMaxLevel(lager.WARN))
+			Expect(warnings(log)).To(BeTrue())
+			Expect(lager.MatchAll(warnings, lager.MatchDataKey("method"))(log)).To(BeFalse())
+			Expect(lager.MatchAny(lager.MatchDataKey("method"), lager.MatchSource("my-app"))(log)).To(BeTrue())
+			Expect(lager.MatchAny()(log)).To(BeFalse())
+			Expect(lager.MatchNot(warnings)(log)).To(BeFalse())
+		})
+	})
+})
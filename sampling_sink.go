@@ -0,0 +1,193 @@
+package lager
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SamplingSummaryMessage is the message of the entries a SamplingSink writes
+// to report what it suppressed.
+const SamplingSummaryMessage = "lager.sampling.suppressed"
+
+// DefaultSamplingInterval is the interval used by NewSamplingSink when the
+// interval given is not positive.
+const DefaultSamplingInterval = time.Second
+
+// SamplingBudget controls how many logs with the same message a SamplingSink
+// lets through in each interval.
+type SamplingBudget struct {
+	// First is the number of logs with a given message written in each
+	// interval before sampling starts.
+	First int
+	// Thereafter writes every Thereafter-th log once First has been used up.
+	// Zero suppresses everything after First.
+	Thereafter int
+}
+
+type samplingKey struct {
+	source  string
+	message string
+	level   LogLevel
+}
+
+// SamplingSink throttles floods of identical messages and periodically writes
+// a summary of what it suppressed.
+type SamplingSink struct {
+	sink     Sink
+	interval time.Duration
+	budgets  map[LogLevel]SamplingBudget
+
+	lock       sync.Mutex
+	counts     map[samplingKey]int
+	suppressed map[samplingKey]int
+
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSamplingSink returns a sink that samples logs by source and message
+// using the budget for their level. Levels without a budget are never
+// sampled. At the end of every interval the counts are reset and, for each
+// source that had logs suppressed, an entry with SamplingSummaryMessage is
+// written with the number of suppressed logs per message.
+//
+// An interval that is not positive is replaced by DefaultSamplingInterval.
+// Close must be called to stop the summary goroutine.
+func NewSamplingSink(sink Sink, interval time.Duration, budgets map[LogLevel]SamplingBudget) *SamplingSink {
+	if interval <= 0 {
+		interval = DefaultSamplingInterval
+	}
+
+	s := &SamplingSink{
+		sink:       sink,
+		interval:   interval,
+		budgets:    budgets,
+		counts:     map[samplingKey]int{},
+		suppressed: map[samplingKey]int{},
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s
+}
+
+func (s *SamplingSink) Log(log LogFormat) {
+	budget, ok := s.budgets[log.LogLevel]
+	if !ok {
+		s.sink.Log(log)
+		return
+	}
+
+	key := samplingKey{source: log.Source, message: log.Message, level: log.LogLevel}
+
+	s.lock.Lock()
+	s.counts[key]++
+	n := s.counts[key]
+	keep := n <= budget.First || (budget.Thereafter > 0 && (n-budget.First)%budget.Thereafter == 0)
+	if !keep {
+		s.suppressed[key]++
+	}
+	s.lock.Unlock()
+
+	if keep {
+		s.sink.Log(log)
+	}
+}
+
+func (s *SamplingSink) Enabled(level LogLevel) bool {
+	return SinkEnabled(s.sink, level)
+}
+
+// Flush writes a summary of the logs suppressed so far, starts a new interval
+// and flushes the wrapped sink.
+func (s *SamplingSink) Flush() error {
+	s.summarize()
+	return FlushSink(s.sink)
+}
+
+// Close stops the summary goroutine, writes a final summary and closes the
+// wrapped sink.
+func (s *SamplingSink) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+	<-s.stopped
+
+	s.summarize()
+	return CloseSink(s.sink)
+}
+
+func (s *SamplingSink) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.summarize()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// summarize resets the counts and writes one summary entry per source. The
+// summary takes the highest level that was suppressed for that source so that
+// level filters further down do not hide it when they would have let the
+// suppressed logs through.
+func (s *SamplingSink) summarize() {
+	s.lock.Lock()
+	suppressed := s.suppressed
+	s.counts = map[samplingKey]int{}
+	s.suppressed = map[samplingKey]int{}
+	s.lock.Unlock()
+
+	if len(suppressed) == 0 {
+		return
+	}
+
+	type summary struct {
+		level    LogLevel
+		messages map[string]int
+	}
+	summaries := map[string]*summary{}
+	for key, count := range suppressed {
+		sum, ok := summaries[key.source]
+		if !ok {
+			sum = &summary{level: key.level, messages: map[string]int{}}
+			summaries[key.source] = sum
+		}
+		if key.level > sum.level {
+			sum.level = key.level
+		}
+		sum.messages[key.message] += count
+	}
+
+	sources := make([]string, 0, len(summaries))
+	for source := range summaries {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	t := time.Now().UTC()
+	for _, source := range sources {
+		sum := summaries[source]
+		s.sink.Log(LogFormat{
+			time:      t,
+			Timestamp: formatTimestamp(t),
+			Source:    source,
+			Message:   SamplingSummaryMessage,
+			LogLevel:  sum.level,
+			Data: Data{
+				"interval":   s.interval.String(),
+				"suppressed": sum.messages,
+			},
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package lager_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SamplingSink", func() {
+	var (
+		testSink *lagertest.TestSink
+		interval time.Duration
+		budgets  map[lager.LogLevel]lager.SamplingBudget
+		sink     *lager.SamplingSink
+	)
+
+	logN := func(n int, level lager.LogLevel, message string) {
+		for i := 0; i < n; i++ {
+			sink.Log(lager.LogFormat{Source: "component", LogLevel: level, Message: message})
+		}
+	}
+
+	countMessages := func(message string) int {
+		count := 0
+		for _, m := range testSink.LogMessages() {
+			if m == message {
+				count++
+			}
+		}
+		return count
+	}
+
+	BeforeEach(func() {
+		testSink = lagertest.NewTestSink()
+		interval = time.Hour
+		budgets = map[lager.LogLevel]lager.SamplingBudget{
+			lager.INFO:  {First: 3, Thereafter: 0},
+			lager.ERROR: {First: 2, Thereafter: 5},
+		}
+	})
+
+	JustBeforeEach(func() {
+		sink = lager.NewSamplingSink(testSink, interval, budgets)
+	})
+
+	AfterEach(func() {
+		Expect(sink.Close()).To(Succeed())
+	})
+
+	It("writes the first logs for each message", func() {
+		logN(3, lager.INFO, "component.flood")
+		logN(3, lager.INFO, "component.other")
+
+		Expect(countMessages("component.flood")).To(Equal(3))
+		Expect(countMessages("component.other")).To(Equal(3))
+	})
+
+	It("suppresses logs beyond the budget", func() {
+		logN(10, lager.INFO, "component.flood")
+
+		Expect(countMessages("component.flood")).To(Equal(3))
+	})
+
+	It("writes every Thereafter-th log once the budget is used up", func() {
+		logN(12, lager.ERROR, "component.failed")
+
+		// 2 up front, then the 7th and 12th
+		Expect(countMessages("component.failed")).To(Equal(4))
+	})
+
+	It("does not sample levels without a budget", func() {
+		logN(10, lager.DEBUG, "component.debug")
+
+		Expect(countMessages("component.debug")).To(Equal(10))
+	})
+
+	It("counts each level separately", func() {
+		logN(3, lager.INFO, "component.mixed")
+		logN(2, lager.ERROR, "component.mixed")
+
+		Expect(countMessages("component.mixed")).To(Equal(5))
+	})
+
+	Describe("the summary", func() {
+		It("reports how many logs were suppressed per message", func() {
+			logN(10, lager.INFO, "component.flood")
+			logN(5, lager.INFO, "component.other")
+			Expect(sink.Flush()).To(Succeed())
+
+			logs := testSink.Logs()
+			summary := logs[len(logs)-1]
+			Expect(summary.Source).To(Equal("component"))
+			Expect(summary.Message).To(Equal(lager.SamplingSummaryMessage))
+			Expect(summary.LogLevel).To(Equal(lager.INFO))
+			Expect(summary.Data["interval"]).To(Equal("1h0m0s"))
+			Expect(summary.Data["suppressed"]).To(Equal(map[string]interface{}{
+				"component.flood": float64(7),
+				"component.other": float64(2),
+			}))
+		})
+
+		It("uses the highest suppressed level", func() {
+			logN(10, lager.INFO, "component.flood")
+			logN(4, lager.ERROR, "component.failed")
+			Expect(sink.Flush()).To(Succeed())
+
+			logs := testSink.Logs()
+			Expect(logs[len(logs)-1].LogLevel).To(Equal(lager.ERROR))
+		})
+
+		It("is not written when nothing was suppressed", func() {
+			logN(1, lager.INFO, "component.quiet")
+			Expect(sink.Flush()).To(Succeed())
+
+			Expect(countMessages(lager.SamplingSummaryMessage)).To(BeZero())
+		})
+
+		It("resets the budget", func() {
+			logN(10, lager.INFO, "component.flood")
+			Expect(sink.Flush()).To(Succeed())
+			logN(10, lager.INFO, "component.flood")
+
+			Expect(countMessages("component.flood")).To(Equal(6))
+		})
+
+		Context("when the interval passes", func() {
+			BeforeEach(func() {
+				interval = 50 * time.Millisecond
+			})
+
+			It("is written automatically", func() {
+				logN(10, lager.INFO, "component.flood")
+
+				Eventually(func() int {
+					return countMessages(lager.SamplingSummaryMessage)
+				}).Should(Equal(1))
+			})
+		})
+	})
+
+	Context("when the interval is not positive", func() {
+		BeforeEach(func() {
+			interval = 0
+		})
+
+		It("uses the default interval instead of panicking", func() {
+			logN(5, lager.INFO, "component.flood")
+			Expect(countMessages("component.flood")).To(Equal(3))
+
+			Eventually(func() int {
+				return countMessages(lager.SamplingSummaryMessage)
+			}, 2*lager.DefaultSamplingInterval).Should(Equal(1))
+		})
+	})
+
+	It("composes with ReconfigurableSink", func() {
+		reconfigurable := lager.NewReconfigurableSink(sink, lager.ERROR)
+		logger := lager.NewLogger("component")
+		logger.RegisterSink(reconfigurable)
+
+		for i := 0; i < 10; i++ {
+			logger.Info("flood")
+			logger.Error("failed", nil)
+		}
+
+		Expect(countMessages("component.flood")).To(BeZero())
+		Expect(countMessages("component.failed")).To(Equal(3))
+	})
+})
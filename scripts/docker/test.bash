@@ -21,5 +21,6 @@ if [[ -n "${1:-}" ]]; then
   test "${1}" "${2:-}"
 else
   test "."
+  test "lagergrpc"
 fi
 popd > /dev/null
@@ -17,6 +17,7 @@ type slogSink struct {
 // Note the following log level conversions:
 //
 //	lager.DEBUG -> slog.LevelDebug
+//	lager.WARN  -> slog.LevelWarn
 //	lager.ERROR -> slog.LevelError
 //	lager.FATAL -> slog.LevelError
 //	default     -> slog.LevelInfo
@@ -55,6 +56,8 @@ func toSlogLevel(l LogLevel) slog.Level {
 	switch l {
 	case DEBUG:
 		return slog.LevelDebug
+	case WARN:
+		return slog.LevelWarn
 	case ERROR, FATAL:
 		return slog.LevelError
 	default:
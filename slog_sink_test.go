@@ -54,6 +54,17 @@ var _ = Describe("NewSlogSink", func() {
 		}))
 	})
 
+	It("logs Warn()", func() {
+		logger.Warn("fake-warn", lager.Data{"foo": "bar"})
+
+		Expect(parsedLogMessage()).To(MatchAllKeys(Keys{
+			"time":  matchTimestamp,
+			"level": Equal("WARN"),
+			"msg":   Equal("fake-component.fake-warn"),
+			"foo":   Equal("bar"),
+		}))
+	})
+
 	It("logs Error()", func() {
 		logger.Error("fake-error", fmt.Errorf("boom"), lager.Data{"foo": "bar"})
 
@@ -0,0 +1,402 @@
+package lager
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SyslogFacility is the facility part of a syslog priority.
+type SyslogFacility int
+
+const (
+	SyslogUser   SyslogFacility = 1
+	SyslogDaemon SyslogFacility = 3
+)
+
+const (
+	SyslogLocal0 SyslogFacility = iota + 16
+	SyslogLocal1
+	SyslogLocal2
+	SyslogLocal3
+	SyslogLocal4
+	SyslogLocal5
+	SyslogLocal6
+	SyslogLocal7
+)
+
+// SyslogFormat decides where a syslog sink puts the log data.
+type SyslogFormat int
+
+const (
+	// SyslogStructuredData writes the data as RFC 5424 structured data,
+	// flattened into dotted keys like NewLogfmtSink, and the log message as
+	// the message.
+	SyslogStructuredData SyslogFormat = iota
+	// SyslogJSONMessage writes the whole log, as NewWriterSink would, as the
+	// message.
+	SyslogJSONMessage
+)
+
+const (
+	// DefaultSyslogSDID is the structured data ID used when
+	// SyslogSinkConfig.SDID is not set.
+	DefaultSyslogSDID = "lager@47450"
+
+	defaultSyslogTimeout    = 5 * time.Second
+	defaultSyslogMinBackoff = 100 * time.Millisecond
+	defaultSyslogMaxBackoff = 30 * time.Second
+	syslogTimeFormat        = "2006-01-02T15:04:05.000000Z07:00"
+	maxSyslogSDNameLen      = 32
+)
+
+// SyslogSinkConfig describes where a SyslogSink sends logs and how they are
+// formatted.
+type SyslogSinkConfig struct {
+	// Network is "udp", "tcp", "unix" or "unixgram". Messages sent over
+	// stream connections are framed with their length, as in RFC 6587.
+	Network string
+	// Address is the host:port, or the socket path, of the collector.
+	Address     string
+	MinLogLevel LogLevel
+
+	// Facility defaults to SyslogUser.
+	Facility SyslogFacility
+	// Hostname defaults to os.Hostname.
+	Hostname string
+	// AppName defaults to the source of each log.
+	AppName string
+	// ProcID defaults to the process ID.
+	ProcID string
+
+	Format SyslogFormat
+	// SDID is the ID of the structured data element holding the log data.
+	// It defaults to DefaultSyslogSDID.
+	SDID string
+	// Timeout bounds connecting and each write. It defaults to five seconds.
+	Timeout time.Duration
+	// MinBackoff and MaxBackoff bound the wait between attempts to
+	// reconnect, which doubles after each failed attempt. They default to
+	// 100ms and 30s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// SyslogSink is a Sink that sends RFC 5424 messages to a syslog collector.
+// When sending fails the log is dropped and the sink reconnects in the
+// background. Logs written before it has reconnected are dropped rather than
+// waiting for the collector.
+type SyslogSink struct {
+	config SyslogSinkConfig
+	framed bool
+
+	dropped   uint64
+	reconnect chan struct{}
+	stop      chan struct{}
+
+	writeL sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// NewSyslogSink connects to the collector at config.Address. If it cannot
+// connect, the sink is returned anyway and keeps trying in the background,
+// dropping logs until it has connected. Only an invalid config is an error.
+func NewSyslogSink(config SyslogSinkConfig) (*SyslogSink, error) {
+	var framed bool
+	switch config.Network {
+	case "udp", "udp4", "udp6", "unixgram":
+	case "tcp", "tcp4", "tcp6", "unix":
+		framed = true
+	default:
+		return nil, errors.New("unsupported syslog network: " + config.Network)
+	}
+
+	if config.Facility == 0 {
+		config.Facility = SyslogUser
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.ProcID == "" {
+		config.ProcID = strconv.Itoa(os.Getpid())
+	}
+	if config.SDID == "" {
+		config.SDID = DefaultSyslogSDID
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultSyslogTimeout
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaultSyslogMinBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultSyslogMaxBackoff
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+
+	sink := &SyslogSink{
+		config:    config,
+		framed:    framed,
+		reconnect: make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+
+	// Like NetworkSink, start even when the collector is down and connect in
+	// the background, so that logging does not depend on it being up first
+	conn, err := net.DialTimeout(config.Network, config.Address, config.Timeout)
+	if err == nil {
+		sink.conn = conn
+	} else {
+		sink.reconnect <- struct{}{}
+	}
+
+	go sink.run()
+
+	return sink, nil
+}
+
+func (sink *SyslogSink) Log(log LogFormat) {
+	if log.LogLevel < sink.config.MinLogLevel {
+		return
+	}
+
+	// Format outside of critical section to minimize time spent holding lock
+	buf := getBuffer()
+	defer putBuffer(buf)
+	*buf = sink.appendMessage(*buf, log)
+
+	if sink.framed {
+		frame := getBuffer()
+		defer putBuffer(frame)
+		*frame = strconv.AppendInt(*frame, int64(len(*buf)), 10)
+		*frame = append(append(*frame, ' '), *buf...)
+		buf = frame
+	}
+
+	sink.writeL.Lock()
+	defer sink.writeL.Unlock()
+
+	if sink.closed {
+		return
+	}
+
+	if sink.conn == nil {
+		atomic.AddUint64(&sink.dropped, 1)
+		return
+	}
+
+	if err := sink.write(*buf); err != nil {
+		sink.conn.Close() //nolint:errcheck
+		sink.conn = nil
+		atomic.AddUint64(&sink.dropped, 1)
+
+		select {
+		case sink.reconnect <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (sink *SyslogSink) Enabled(level LogLevel) bool {
+	return level >= sink.config.MinLogLevel
+}
+
+// Dropped returns the number of logs that could not be sent.
+func (sink *SyslogSink) Dropped() uint64 {
+	return atomic.LoadUint64(&sink.dropped)
+}
+
+// Close closes the connection and stops reconnecting. Logs written after
+// Close are discarded.
+func (sink *SyslogSink) Close() error {
+	sink.writeL.Lock()
+	defer sink.writeL.Unlock()
+
+	if sink.closed {
+		return nil
+	}
+	sink.closed = true
+	close(sink.stop)
+
+	if sink.conn == nil {
+		return nil
+	}
+	return sink.conn.Close()
+}
+
+// run reconnects each time a write fails, waiting longer after each failed
+// attempt. It dials without holding writeL so that logging is never held up
+// by the collector.
+func (sink *SyslogSink) run() {
+	for {
+		select {
+		case <-sink.reconnect:
+		case <-sink.stop:
+			return
+		}
+
+		backoff := sink.config.MinBackoff
+		for {
+			conn, err := net.DialTimeout(sink.config.Network, sink.config.Address, sink.config.Timeout)
+			if err == nil {
+				sink.writeL.Lock()
+				if sink.closed {
+					sink.writeL.Unlock()
+					conn.Close() //nolint:errcheck
+					return
+				}
+				sink.conn = conn
+				sink.writeL.Unlock()
+				break
+			}
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-sink.stop:
+				timer.Stop()
+				return
+			}
+
+			backoff *= 2
+			if backoff > sink.config.MaxBackoff {
+				backoff = sink.config.MaxBackoff
+			}
+		}
+	}
+}
+
+// write sends one message, already framed if needed. The caller must hold
+// writeL.
+func (sink *SyslogSink) write(msg []byte) error {
+	sink.conn.SetWriteDeadline(time.Now().Add(sink.config.Timeout)) //nolint:errcheck
+	_, err := sink.conn.Write(msg)
+	return err
+}
+
+// appendMessage formats the log as
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (sink *SyslogSink) appendMessage(dst []byte, log LogFormat) []byte {
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(sink.config.Facility)*8+int64(syslogSeverity(log.LogLevel)), 10)
+	dst = append(dst, ">1 "...)
+
+	t := log.time
+	if t.IsZero() {
+		t = parseTimestamp(log.Timestamp)
+	}
+	dst = t.UTC().AppendFormat(dst, syslogTimeFormat)
+
+	appName := sink.config.AppName
+	if appName == "" {
+		appName = log.Source
+	}
+
+	dst = append(dst, ' ')
+	dst = appendSyslogHeaderField(dst, sink.config.Hostname, 255)
+	dst = append(dst, ' ')
+	dst = appendSyslogHeaderField(dst, appName, 48)
+	dst = append(dst, ' ')
+	dst = appendSyslogHeaderField(dst, sink.config.ProcID, 128)
+	dst = append(dst, " - "...)
+
+	if sink.config.Format == SyslogJSONMessage {
+		dst = append(dst, "- "...)
+		return log.appendJSON(dst)
+	}
+
+	if len(log.Data) == 0 {
+		dst = append(dst, '-')
+	} else {
+		dst = append(dst, '[')
+		dst = appendSyslogSDName(dst, sink.config.SDID)
+		flattenData("", log.Data, func(key, value string, _ bool) {
+			dst = append(dst, ' ')
+			dst = appendSyslogSDName(dst, key)
+			dst = append(dst, `="`...)
+			dst = appendSyslogParamValue(dst, value)
+			dst = append(dst, '"')
+		})
+		dst = append(dst, ']')
+	}
+
+	dst = append(dst, ' ')
+	return append(dst, log.Message...)
+}
+
+// syslogSeverity maps a log level to the closest syslog severity.
+func syslogSeverity(level LogLevel) int {
+	switch level {
+	case DEBUG:
+		return 7
+	case INFO:
+		return 6
+	case WARN:
+		return 4
+	case ERROR:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// appendSyslogHeaderField writes a header field as printable ASCII of at most
+// max bytes, or "-" when it is empty.
+func appendSyslogHeaderField(dst []byte, s string, max int) []byte {
+	if s == "" {
+		return append(dst, '-')
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c > ' ' && c < 0x7f {
+			dst = append(dst, c)
+		} else {
+			dst = append(dst, '_')
+		}
+	}
+	return dst
+}
+
+// appendSyslogSDName writes a structured data ID or parameter name, replacing
+// characters that are not allowed with underscores and truncating it to 32
+// bytes.
+func appendSyslogSDName(dst []byte, s string) []byte {
+	if s == "" {
+		return append(dst, '_')
+	}
+	if len(s) > maxSyslogSDNameLen {
+		s = s[:maxSyslogSDNameLen]
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || c == '=' || c == ']' || c == '"' {
+			c = '_'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// appendSyslogParamValue escapes the characters that would end a structured
+// data parameter value.
+func appendSyslogParamValue(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\', ']':
+			dst = append(dst, '\\', c)
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
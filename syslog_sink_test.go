@@ -0,0 +1,359 @@
+package lager_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyslogSink", func() {
+	var (
+		config lager.SyslogSinkConfig
+		sink   *lager.SyslogSink
+		log    lager.LogFormat
+	)
+
+	BeforeEach(func() {
+		config = lager.SyslogSinkConfig{
+			MinLogLevel: lager.INFO,
+			Hostname:    "my-host",
+			ProcID:      "42",
+		}
+		log = lager.LogFormat{
+			Timestamp: "1709296496.789000000",
+			Source:    "my-app",
+			Message:   "my-app.did-something",
+			LogLevel:  lager.INFO,
+			Data:      lager.Data{"count": 3},
+		}
+	})
+
+	AfterEach(func() {
+		if sink != nil {
+			Expect(sink.Close()).To(Succeed())
+			sink = nil
+		}
+	})
+
+	newSink := func() {
+		var err error
+		sink, err = lager.NewSyslogSink(config)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// readFrames reads octet-counted messages from every connection accepted
+	// by the listener. Sending to drop closes the connections accepted so far.
+	readFrames := func(listener net.Listener) (chan string, chan struct{}) {
+		messages := make(chan string, 100)
+		drop := make(chan struct{})
+		conns := make(chan net.Conn, 10)
+
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conns <- conn
+				go func() {
+					reader := bufio.NewReader(conn)
+					for {
+						length, err := reader.ReadString(' ')
+						if err != nil {
+							return
+						}
+						n, err := strconv.Atoi(strings.TrimSpace(length))
+						if err != nil {
+							return
+						}
+						msg := make([]byte, n)
+						if _, err := io.ReadFull(reader, msg); err != nil {
+							return
+						}
+						messages <- string(msg)
+					}
+				}()
+			}
+		}()
+
+		go func() {
+			for range drop {
+				for len(conns) > 0 {
+					(<-conns).Close()
+				}
+			}
+		}()
+		DeferCleanup(func() {
+			close(drop)
+			for len(conns) > 0 {
+				(<-conns).Close()
+			}
+		})
+
+		return messages, drop
+	}
+
+	readPackets := func(conn net.PacketConn) chan string {
+		messages := make(chan string, 100)
+		go func() {
+			buf := make([]byte, 64*1024)
+			for {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				messages <- string(buf[:n])
+			}
+		}()
+		return messages
+	}
+
+	Context("over UDP", func() {
+		var messages chan string
+
+		BeforeEach(func() {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(conn.Close)
+
+			messages = readPackets(conn)
+			config.Network = "udp"
+			config.Address = conn.LocalAddr().String()
+		})
+
+		It("sends each log as an RFC 5424 message with the data as structured data", func() {
+			newSink()
+			sink.Log(log)
+
+			Eventually(messages).Should(Receive(Equal(
+				`<14>1 2024-03-01T12:34:56.789000Z my-host my-app 42 - [lager@47450 count="3"] my-app.did-something`,
+			)))
+		})
+
+		It("maps levels to syslog severities", func() {
+			config.MinLogLevel = lager.DEBUG
+			config.Facility = lager.SyslogLocal0
+			newSink()
+
+			for _, level := range []lager.LogLevel{lager.DEBUG, lager.INFO, lager.WARN, lager.ERROR, lager.FATAL} {
+				log.LogLevel = level
+				sink.Log(log)
+			}
+
+			for _, pri := range []string{"<135>", "<134>", "<132>", "<131>", "<130>"} {
+				Eventually(messages).Should(Receive(HavePrefix(pri)))
+			}
+		})
+
+		It("does not send logs below the minimum level", func() {
+			newSink()
+			log.LogLevel = lager.DEBUG
+			sink.Log(log)
+
+			Consistently(messages, 100*time.Millisecond).ShouldNot(Receive())
+			Expect(sink.Enabled(lager.DEBUG)).To(BeFalse())
+			Expect(sink.Enabled(lager.INFO)).To(BeTrue())
+		})
+
+		It("flattens and escapes the structured data", func() {
+			config.SDID = "my-app@12345"
+			newSink()
+			log.Data = lager.Data{
+				"request":   map[string]interface{}{"path": "/v1/things", "ids": []interface{}{1, 2}},
+				"quoted":    `say "hi" [now]`,
+				"bad key=1": nil,
+			}
+			sink.Log(log)
+
+			Eventually(messages).Should(Receive(Equal(
+				`<14>1 2024-03-01T12:34:56.789000Z my-host my-app 42 - [my-app@12345 bad_key_1="" quoted="say \"hi\" [now\]" request.ids.0="1" request.ids.1="2" request.path="/v1/things"] my-app.did-something`,
+			)))
+		})
+
+		It("writes a nil value for logs without data", func() {
+			newSink()
+			log.Data = nil
+			sink.Log(log)
+
+			Eventually(messages).Should(Receive(HaveSuffix(" 42 - - my-app.did-something")))
+		})
+
+		It("can send the log as JSON instead", func() {
+			config.Format = lager.SyslogJSONMessage
+			config.AppName = "my-tag"
+			newSink()
+			sink.Log(log)
+
+			Eventually(messages).Should(Receive(Equal(
+				`<14>1 2024-03-01T12:34:56.789000Z my-host my-tag 42 - - ` + string(log.ToJSON()),
+			)))
+		})
+
+		It("discards logs after it is closed", func() {
+			newSink()
+			Expect(sink.Close()).To(Succeed())
+			sink.Log(log)
+
+			Consistently(messages, 100*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+
+	Context("over TCP", func() {
+		var (
+			listener net.Listener
+			messages chan string
+			drop     chan struct{}
+		)
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(func() {
+				listener.Close() //nolint:errcheck
+			})
+
+			messages, drop = readFrames(listener)
+			config.Network = "tcp"
+			config.Address = listener.Addr().String()
+		})
+
+		It("frames each message with its length", func() {
+			newSink()
+			sink.Log(log)
+			log.Message = "my-app.did-something else\nentirely"
+			sink.Log(log)
+
+			Eventually(messages).Should(Receive(HaveSuffix("] my-app.did-something")))
+			Eventually(messages).Should(Receive(HaveSuffix("] my-app.did-something else\nentirely")))
+		})
+
+		It("uses the default timeout when it is not positive", func() {
+			config.Timeout = -time.Second
+			newSink()
+			sink.Log(log)
+
+			Eventually(messages).Should(Receive(HaveSuffix("] my-app.did-something")))
+			Expect(sink.Dropped()).To(BeZero())
+		})
+
+		It("reconnects when the connection is closed", func() {
+			newSink()
+			sink.Log(log)
+			Eventually(messages).Should(Receive())
+
+			drop <- struct{}{}
+
+			Eventually(func() chan string {
+				sink.Log(log)
+				return messages
+			}).Should(Receive(HaveSuffix("] my-app.did-something")))
+		})
+
+		It("drops logs while the collector is down and reconnects with backoff", func() {
+			config.MinBackoff = 10 * time.Millisecond
+			config.MaxBackoff = 20 * time.Millisecond
+			newSink()
+
+			Expect(listener.Close()).To(Succeed())
+			drop <- struct{}{}
+
+			Eventually(func() uint64 {
+				sink.Log(log)
+				return sink.Dropped()
+			}).Should(BeNumerically(">", 0))
+
+			start := time.Now()
+			for i := 0; i < 100; i++ {
+				sink.Log(log)
+			}
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+
+			listener, err := net.Listen("tcp", config.Address)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(listener.Close)
+			messages, _ := readFrames(listener)
+
+			Eventually(func() chan string {
+				sink.Log(log)
+				return messages
+			}).Should(Receive(HaveSuffix("] my-app.did-something")))
+		})
+	})
+
+	Context("over a unix socket", func() {
+		var socketPath string
+
+		BeforeEach(func() {
+			dir, err := os.MkdirTemp("", "syslog")
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(os.RemoveAll, dir)
+
+			socketPath = filepath.Join(dir, "log.sock")
+			config.Address = socketPath
+		})
+
+		It("sends framed messages over stream sockets", func() {
+			listener, err := net.Listen("unix", socketPath)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(listener.Close)
+			messages, _ := readFrames(listener)
+
+			config.Network = "unix"
+			newSink()
+			sink.Log(log)
+
+			Eventually(messages).Should(Receive(HaveSuffix(`[lager@47450 count="3"] my-app.did-something`)))
+		})
+
+		It("sends one message per datagram over datagram sockets", func() {
+			conn, err := net.ListenPacket("unixgram", socketPath)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(conn.Close)
+			messages := readPackets(conn)
+
+			config.Network = "unixgram"
+			newSink()
+			sink.Log(log)
+
+			Eventually(messages).Should(Receive(HavePrefix("<14>1 ")))
+		})
+	})
+
+	It("starts without the collector and connects once it is up", func() {
+		config.Network = "unix"
+		config.Address = filepath.Join(GinkgoT().TempDir(), "log.sock")
+		config.MinBackoff = 10 * time.Millisecond
+		config.MaxBackoff = 20 * time.Millisecond
+		newSink()
+
+		sink.Log(log)
+		Expect(sink.Dropped()).To(Equal(uint64(1)))
+
+		listener, err := net.Listen("unix", config.Address)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(listener.Close)
+		messages, _ := readFrames(listener)
+
+		Eventually(func() chan string {
+			sink.Log(log)
+			return messages
+		}).Should(Receive(HaveSuffix("] my-app.did-something")))
+	})
+
+	It("rejects unsupported networks", func() {
+		config.Network = "ip4"
+
+		_, err := lager.NewSyslogSink(config)
+		Expect(err).To(MatchError(ContainSubstring("unsupported syslog network")))
+	})
+})
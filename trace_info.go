@@ -0,0 +1,299 @@
+package lager
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/openzipkin/zipkin-go/idgenerator"
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+const (
+	b3Header             = "b3"
+	b3TraceIDHeader      = "X-B3-TraceId"
+	b3SpanIDHeader       = "X-B3-SpanId"
+	b3ParentSpanIDHeader = "X-B3-ParentSpanId"
+	b3SampledHeader      = "X-B3-Sampled"
+	b3FlagsHeader        = "X-B3-Flags"
+)
+
+// traceContext is the trace an incoming request belongs to.
+type traceContext struct {
+	traceID model.TraceID
+	// parentSpanID is the caller's span, which is the parent of the span
+	// WithTraceInfo starts. Zero when the header format does not carry one.
+	parentSpanID model.ID
+	sampled      *bool
+	traceState   string
+}
+
+// traceContextFromRequest reads the trace from the first header format that
+// is present and valid, in order: W3C traceparent, B3 single header, B3
+// multiple headers and finally X-Vcap-Request-Id.
+func traceContextFromRequest(req *http.Request) (traceContext, bool) {
+	if tc, ok := parseTraceparent(req.Header.Get(TraceparentHeader)); ok {
+		tc.traceState = strings.Join(req.Header.Values(TracestateHeader), ",")
+		return tc, true
+	}
+
+	if tc, ok := parseB3SingleHeader(req.Header.Get(b3Header)); ok {
+		return tc, true
+	}
+
+	if tc, ok := parseB3Headers(req.Header); ok {
+		return tc, true
+	}
+
+	traceIDHeader := req.Header.Get(RequestIdHeader)
+	if traceIDHeader == "" {
+		return traceContext{}, false
+	}
+	traceID, err := model.TraceIDFromHex(strings.Replace(traceIDHeader, "-", "", -1))
+	if err != nil {
+		return traceContext{}, false
+	}
+	return traceContext{traceID: traceID}, true
+}
+
+// parseB3SingleHeader parses a B3 single header, e.g.
+// "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90".
+// Headers that only carry a sampling decision have no trace to read.
+func parseB3SingleHeader(header string) (traceContext, bool) {
+	parts := strings.Split(header, "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return traceContext{}, false
+	}
+
+	tc, ok := parseB3IDs(parts[0], parts[1])
+	if !ok {
+		return traceContext{}, false
+	}
+
+	if len(parts) > 2 {
+		var sampled bool
+		switch parts[2] {
+		case "1", "d":
+			sampled = true
+		case "0":
+		default:
+			return traceContext{}, false
+		}
+		tc.sampled = &sampled
+	}
+
+	if len(parts) > 3 {
+		if _, ok := parseB3SpanID(parts[3]); !ok {
+			return traceContext{}, false
+		}
+	}
+
+	return tc, true
+}
+
+// parseB3Headers parses B3 multiple headers. A debug flag counts as sampled.
+func parseB3Headers(header http.Header) (traceContext, bool) {
+	tc, ok := parseB3IDs(header.Get(b3TraceIDHeader), header.Get(b3SpanIDHeader))
+	if !ok {
+		return traceContext{}, false
+	}
+
+	if parentSpanID := header.Get(b3ParentSpanIDHeader); parentSpanID != "" {
+		if _, ok := parseB3SpanID(parentSpanID); !ok {
+			return traceContext{}, false
+		}
+	}
+
+	var sampled bool
+	switch header.Get(b3SampledHeader) {
+	case "1", "true":
+		sampled = true
+		tc.sampled = &sampled
+	case "0", "false":
+		tc.sampled = &sampled
+	case "":
+	default:
+		return traceContext{}, false
+	}
+
+	switch header.Get(b3FlagsHeader) {
+	case "1":
+		sampled = true
+		tc.sampled = &sampled
+	case "", "0":
+	default:
+		return traceContext{}, false
+	}
+
+	return tc, true
+}
+
+// parseB3IDs parses the trace ID and span ID of B3 headers. The span is the
+// caller's, and so the parent of the span WithTraceInfo starts.
+func parseB3IDs(traceIDHex, spanIDHex string) (traceContext, bool) {
+	if len(traceIDHex) == 0 || len(traceIDHex) > 32 {
+		return traceContext{}, false
+	}
+	traceID, err := model.TraceIDFromHex(traceIDHex)
+	if err != nil || traceID.Empty() {
+		return traceContext{}, false
+	}
+
+	spanID, ok := parseB3SpanID(spanIDHex)
+	if !ok {
+		return traceContext{}, false
+	}
+
+	return traceContext{traceID: traceID, parentSpanID: spanID}, true
+}
+
+func parseB3SpanID(spanIDHex string) (model.ID, bool) {
+	if len(spanIDHex) == 0 || len(spanIDHex) > 16 {
+		return 0, false
+	}
+	spanID, err := strconv.ParseUint(spanIDHex, 16, 64)
+	if err != nil || spanID == 0 {
+		return 0, false
+	}
+	return model.ID(spanID), true
+}
+
+// parseTraceparent parses a W3C Trace Context traceparent header, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func parseTraceparent(header string) (traceContext, bool) {
+	const length = 55
+
+	if len(header) < length || (len(header) > length && header[length] != '-') {
+		return traceContext{}, false
+	}
+	if header[2] != '-' || header[35] != '-' || header[52] != '-' {
+		return traceContext{}, false
+	}
+
+	version, traceIDHex, parentIDHex, flagsHex := header[0:2], header[3:35], header[36:52], header[53:55]
+	if !isLowerHex(version) || !isLowerHex(traceIDHex) || !isLowerHex(parentIDHex) || !isLowerHex(flagsHex) {
+		return traceContext{}, false
+	}
+	if version == "ff" || (version == "00" && len(header) != length) {
+		return traceContext{}, false
+	}
+
+	traceID, err := model.TraceIDFromHex(traceIDHex)
+	if err != nil || traceID.Empty() {
+		return traceContext{}, false
+	}
+
+	parentSpanID, err := strconv.ParseUint(parentIDHex, 16, 64)
+	if err != nil || parentSpanID == 0 {
+		return traceContext{}, false
+	}
+
+	flags, err := strconv.ParseUint(flagsHex, 16, 8)
+	if err != nil {
+		return traceContext{}, false
+	}
+	sampled := flags&0x01 == 0x01
+
+	return traceContext{
+		traceID:      traceID,
+		parentSpanID: model.ID(parentSpanID),
+		sampled:      &sampled,
+	}, true
+}
+
+// isLowerHex reports whether s is made of lowercase hex digits only, as the
+// W3C format requires.
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// TraceInfo identifies the trace and span that logs belong to, as recorded
+// by WithTraceInfo.
+type TraceInfo struct {
+	TraceID string
+	SpanID  string
+	// ParentSpanID is the caller's span. It is empty when the headers the
+	// trace came from do not carry one.
+	ParentSpanID string
+	// Sampled is the caller's sampling decision, if it made one.
+	Sampled    *bool
+	TraceState string
+}
+
+var traceIDGenerator = idgenerator.NewRandom128()
+
+// NewTraceInfo reads the trace of the request the same way WithTraceInfo
+// does and starts a new span in it. It returns false when the request does
+// not carry a trace.
+func NewTraceInfo(req *http.Request) (TraceInfo, bool) {
+	tc, ok := traceContextFromRequest(req)
+	if !ok {
+		return TraceInfo{}, false
+	}
+	return tc.traceInfo(traceIDGenerator.SpanID(model.TraceID{})), true
+}
+
+func (tc traceContext) traceInfo(spanID model.ID) TraceInfo {
+	info := TraceInfo{
+		TraceID:    tc.traceID.String(),
+		SpanID:     spanID.String(),
+		Sampled:    tc.sampled,
+		TraceState: tc.traceState,
+	}
+	if tc.parentSpanID != 0 {
+		info.ParentSpanID = tc.parentSpanID.String()
+	}
+	return info
+}
+
+// Data returns the trace as log data.
+func (t TraceInfo) Data() Data {
+	data := Data{
+		"trace-id": t.TraceID,
+		"span-id":  t.SpanID,
+	}
+	if t.ParentSpanID != "" {
+		data["parent-span-id"] = t.ParentSpanID
+	}
+	if t.Sampled != nil {
+		data["sampled"] = *t.Sampled
+	}
+	if t.TraceState != "" {
+		data["trace-state"] = t.TraceState
+	}
+	return data
+}
+
+// Traceparent formats the trace as a W3C traceparent header for requests made
+// from the span. Traces without a sampling decision are sent as not sampled.
+func (t TraceInfo) Traceparent() string {
+	flags := "00"
+	if t.Sampled != nil && *t.Sampled {
+		flags = "01"
+	}
+	return "00-" + padTraceID(t.TraceID) + "-" + t.SpanID + "-" + flags
+}
+
+// RequestID formats the trace ID as an X-Vcap-Request-Id header.
+func (t TraceInfo) RequestID() string {
+	id := padTraceID(t.TraceID)
+	return id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:32]
+}
+
+// padTraceID widens 64-bit trace IDs, which B3 allows, to 128 bits.
+func padTraceID(traceID string) string {
+	if len(traceID) >= 32 {
+		return traceID
+	}
+	return strings.Repeat("0", 32-len(traceID)) + traceID
+}
@@ -31,3 +31,15 @@ func (sink *truncatingSink) Log(log LogFormat) {
 	log.Data = truncatedData
 	sink.sink.Log(log)
 }
+
+func (sink *truncatingSink) Enabled(level LogLevel) bool {
+	return SinkEnabled(sink.sink, level)
+}
+
+func (sink *truncatingSink) Flush() error {
+	return FlushSink(sink.sink)
+}
+
+func (sink *truncatingSink) Close() error {
+	return CloseSink(sink.sink)
+}
@@ -6,12 +6,65 @@ import (
 )
 
 // A Sink represents a write destination for a Logger. It provides
-// a thread-safe interface for writing logs
+// a thread-safe interface for writing logs.
+//
+// The Data of a LogFormat may be shared with the Logger and other sinks, so a
+// Sink that needs to change it must make a copy rather than modify it in place.
 type Sink interface {
 	//Log to the sink.  Best effort -- no need to worry about errors.
 	Log(LogFormat)
 }
 
+// A LevelledSink is a Sink that discards logs below some level. Sinks that
+// wrap another sink should implement it by asking the wrapped sink, so that a
+// Logger can skip building logs that no sink would write.
+type LevelledSink interface {
+	Sink
+	// Enabled reports whether the sink might write a log at the level.
+	Enabled(LogLevel) bool
+}
+
+// SinkEnabled reports whether the sink might write a log at the level. Sinks
+// that do not implement LevelledSink are assumed to write every level.
+func SinkEnabled(sink Sink, level LogLevel) bool {
+	if s, ok := sink.(LevelledSink); ok {
+		return s.Enabled(level)
+	}
+	return true
+}
+
+// A Flusher is a Sink that buffers logs. Flush writes everything logged so
+// far to its destination. Sinks that wrap another sink should implement it by
+// flushing the wrapped sink.
+type Flusher interface {
+	Sink
+	Flush() error
+}
+
+// A Closer is a Sink that holds resources, such as files, connections or
+// goroutines, that must be released when the process shuts down. Sinks that
+// wrap another sink should implement it by closing the wrapped sink.
+type Closer interface {
+	Sink
+	Close() error
+}
+
+// FlushSink flushes the sink if it implements Flusher.
+func FlushSink(sink Sink) error {
+	if s, ok := sink.(Flusher); ok {
+		return s.Flush()
+	}
+	return nil
+}
+
+// CloseSink closes the sink if it implements Closer.
+func CloseSink(sink Sink) error {
+	if s, ok := sink.(Closer); ok {
+		return s.Close()
+	}
+	return nil
+}
+
 type writerSink struct {
 	writer      io.Writer
 	minLogLevel LogLevel
@@ -32,11 +85,18 @@ func (sink *writerSink) Log(log LogFormat) {
 	}
 
 	// Convert to json outside of critical section to minimize time spent holding lock
-	message := append(log.ToJSON(), '\n')
+	buf := getBuffer()
+	*buf = append(log.appendJSON(*buf), '\n')
 
 	sink.writeL.Lock()
-	sink.writer.Write(message) //nolint:errcheck
+	sink.writer.Write(*buf) //nolint:errcheck
 	sink.writeL.Unlock()
+
+	putBuffer(buf)
+}
+
+func (sink *writerSink) Enabled(level LogLevel) bool {
+	return level >= sink.minLogLevel
 }
 
 type prettySink struct {
@@ -58,9 +118,16 @@ func (sink *prettySink) Log(log LogFormat) {
 	}
 
 	// Convert to json outside of critical section to minimize time spent holding lock
-	message := append(log.toPrettyJSON(), '\n')
+	buf := getBuffer()
+	*buf = append(log.appendPrettyJSON(*buf), '\n')
 
 	sink.writeL.Lock()
-	sink.writer.Write(message) //nolint:errcheck
+	sink.writer.Write(*buf) //nolint:errcheck
 	sink.writeL.Unlock()
+
+	putBuffer(buf)
+}
+
+func (sink *prettySink) Enabled(level LogLevel) bool {
+	return level >= sink.minLogLevel
 }
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -65,6 +66,13 @@ var _ = Describe("WriterSink", func() {
 		})
 	})
 
+	It("reports the levels it writes", func() {
+		levelledSink, ok := sink.(lager.LevelledSink)
+		Expect(ok).To(BeTrue())
+		Expect(levelledSink.Enabled(lager.DEBUG)).To(BeFalse())
+		Expect(levelledSink.Enabled(lager.INFO)).To(BeTrue())
+	})
+
 	Context("when logging from multiple threads", func() {
 		var content = "abcdefg "
 
@@ -310,6 +318,80 @@ var _ = Describe("PrettyPrintWriter", func() {
 	})
 })
 
+var _ = Describe("Sink lifecycle", func() {
+	var inner *lifecycleSink
+
+	BeforeEach(func() {
+		inner = &lifecycleSink{}
+	})
+
+	It("does nothing for sinks that neither buffer nor hold resources", func() {
+		sink := lager.NewWriterSink(gbytes.NewBuffer(), lager.INFO)
+		Expect(lager.FlushSink(sink)).To(Succeed())
+		Expect(lager.CloseSink(sink)).To(Succeed())
+	})
+
+	It("flushes and closes sinks that implement Flusher and Closer", func() {
+		inner.err = errors.New("disk full")
+
+		Expect(lager.FlushSink(inner)).To(MatchError("disk full"))
+		Expect(lager.CloseSink(inner)).To(MatchError("disk full"))
+		Expect(inner.flushes).To(Equal(1))
+		Expect(inner.closes).To(Equal(1))
+	})
+
+	DescribeTable("wrapper sinks pass Flush and Close on to the sink they wrap",
+		func(wrap func(lager.Sink) lager.Sink) {
+			sink := wrap(inner)
+
+			Expect(lager.FlushSink(sink)).To(Succeed())
+			Expect(inner.flushes).To(Equal(1))
+
+			Expect(lager.CloseSink(sink)).To(Succeed())
+			Expect(inner.closes).To(Equal(1))
+		},
+		Entry("ReconfigurableSink", func(s lager.Sink) lager.Sink {
+			return lager.NewReconfigurableSink(s, lager.INFO)
+		}),
+		Entry("RedactingSink", func(s lager.Sink) lager.Sink {
+			sink, err := lager.NewRedactingSink(s, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			return sink
+		}),
+		Entry("TruncatingSink", func(s lager.Sink) lager.Sink {
+			return lager.NewTruncatingSink(s, 20)
+		}),
+		Entry("RoutingSink", func(s lager.Sink) lager.Sink {
+			return lager.NewRoutingSink(lager.RouteAllMatches, lager.Route{Sink: s})
+		}),
+		Entry("AsyncSink", func(s lager.Sink) lager.Sink {
+			return lager.NewAsyncSink(s, 10, lager.OverflowBlock, lager.DEBUG)
+		}),
+		Entry("SamplingSink", func(s lager.Sink) lager.Sink {
+			return lager.NewSamplingSink(s, time.Hour, nil)
+		}),
+	)
+})
+
+// lifecycleSink counts calls to Flush and Close, which return err.
+type lifecycleSink struct {
+	flushes int
+	closes  int
+	err     error
+}
+
+func (s *lifecycleSink) Log(lager.LogFormat) {}
+
+func (s *lifecycleSink) Flush() error {
+	s.flushes++
+	return s.err
+}
+
+func (s *lifecycleSink) Close() error {
+	s.closes++
+	return s.err
+}
+
 // copyWriter is an INTENTIONALLY UNSAFE writer. Use it to test code that
 // should be handling thread safety.
 type copyWriter struct {